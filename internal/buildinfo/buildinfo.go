@@ -0,0 +1,63 @@
+// Package buildinfo describes the running binary.
+// Version, Commit and BuildTime are set at build time with
+//
+//	go build -ldflags "-X car-rental/internal/buildinfo.Version=v1.2.0 -X car-rental/internal/buildinfo.Commit=$(git rev-parse HEAD) -X car-rental/internal/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// and otherwise fall back to the VCS stamp Go embeds in the binary.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+var startedAt = time.Now()
+
+// Info is the build metadata served by /version
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Modified  bool   `json:"modified"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+	StartedAt string `json:"started_at"`
+	Uptime    string `json:"uptime"`
+}
+
+// Get returns the build metadata of the running binary
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+		StartedAt: startedAt.UTC().Format(time.RFC3339),
+		Uptime:    time.Since(startedAt).Round(time.Second).String(),
+	}
+
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildTime == "" {
+					info.BuildTime = setting.Value
+				}
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+
+	return info
+}
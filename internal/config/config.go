@@ -0,0 +1,453 @@
+// Package config loads the application settings once at startup.
+// Values come from defaults, then an optional env file, then the process
+// environment, then command line flags, and are validated before use.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/joho/godotenv"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Secret is a setting that must never be printed, it formats as ******
+type Secret string
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return "******"
+}
+
+// GoString keeps %#v from leaking the value
+func (s Secret) GoString() string {
+	return s.String()
+}
+
+// MarshalText keeps JSON and other encoders from leaking the value
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Value returns the secret itself
+func (s Secret) Value() string {
+	return string(s)
+}
+
+type Config struct {
+	App      App
+	Database Database
+	SMTP     SMTP
+	JWT      JWT
+	Xendit   Xendit
+	Workers  Workers
+	OIDC     OIDC
+	SMS      SMS
+	Login    Login
+	Rentals  Rentals
+}
+
+type App struct {
+	Env              string // development/production
+	ListenAddr       string
+	ShutdownTimeout  time.Duration // how long in-flight requests get to finish
+	ReadyTimeout     time.Duration // per readiness check
+	ReadyCacheTTL    time.Duration // how long a readiness result is reused
+	URL              string        // public base URL used in emailed links
+	PasswordResetURL string        // frontend page that takes the reset token and the new password
+	RateLimitStore   string        // memory/database
+	TrustedProxies   []string      // IPs or CIDR ranges of the reverse proxies allowed to set X-Forwarded-For
+	MetricsToken     Secret        // bearer token for /metrics, open when empty (development only)
+	UploadDir        string        // where KYC documents are stored
+}
+
+type Database struct {
+	Host            string
+	Port            string
+	User            string
+	Password        Secret
+	Name            string
+	SSLMode         string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	AutoMigrate     bool
+}
+
+type SMTP struct {
+	Host     string
+	Port     int
+	User     string
+	Password Secret
+	From     string
+}
+
+type JWT struct {
+	Secret          Secret
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	KeyDir          string // asymmetric signing keys, HS256 with Secret when empty
+	SigningAlg      string
+	SigningKID      string
+	KeyRotation     time.Duration
+}
+
+type Xendit struct {
+	SecretKey Secret
+}
+
+type Workers struct {
+	Outbox  int
+	Webhook int
+}
+
+// OIDC login is disabled unless Issuer, ClientID and RedirectURL are set
+type OIDC struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret Secret
+	RedirectURL  string
+	Mock         bool   // serve a mock provider at the Issuer path, development only
+	MockEmail    string // email the mock provider signs in as
+}
+
+// SMS and WhatsApp are unavailable when ProviderURL is empty
+type SMS struct {
+	ProviderURL string
+	Token       Secret
+	Sender      string
+	Stub        bool // development without a provider, messages are only logged
+}
+
+type Login struct {
+	MaxFailures     int           // failed passwords before the account is locked
+	LockoutDuration time.Duration // how long a locked account stays locked
+	TOTPIssuer      string        // issuer label shown in authenticator apps
+}
+
+type Rentals struct {
+	WaitlistHold time.Duration // how long an offered unit stays reserved
+	PickupLead   time.Duration // how long before the start a pickup reminder is sent
+	ReturnLead   time.Duration // how long before the end a return reminder is sent
+}
+
+var sslModes = map[string]bool{
+	"disable": true, "allow": true, "prefer": true,
+	"require": true, "verify-ca": true, "verify-full": true,
+}
+
+// Load reads the configuration and returns it with the arguments left after the flags
+func Load(args []string) (*Config, []string, error) {
+	flags := flag.NewFlagSet("car-rental", flag.ContinueOnError)
+	file := flags.String("config", "", "env file to read settings from (default .env when present)")
+	addr := flags.String("addr", "", "listen address, overrides LISTEN_ADDR")
+	sslMode := flags.String("db-sslmode", "", "database SSL mode, overrides DB_SSLMODE")
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if err := loadFile(*file); err != nil {
+		return nil, nil, err
+	}
+
+	cfg, envErr := fromEnv()
+	if *addr != "" {
+		cfg.App.ListenAddr = *addr
+	}
+	if *sslMode != "" {
+		cfg.Database.SSLMode = *sslMode
+	}
+
+	if err := errors.Join(envErr, cfg.Validate()); err != nil {
+		return nil, nil, err
+	}
+	return cfg, flags.Args(), nil
+}
+
+// loadFile reads an env file without overriding variables already set,
+// the default .env is optional but an explicitly named file must exist
+func loadFile(path string) error {
+	if path == "" {
+		if _, err := os.Stat(".env"); err != nil {
+			return nil
+		}
+		path = ".env"
+	}
+
+	if err := godotenv.Load(path); err != nil {
+		return fmt.Errorf("load config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Defaults returns the settings used when nothing overrides them
+func Defaults() Config {
+	return Config{
+		App: App{
+			Env:              "development",
+			ListenAddr:       ":8080",
+			ShutdownTimeout:  30 * time.Second,
+			ReadyTimeout:     2 * time.Second,
+			ReadyCacheTTL:    10 * time.Second,
+			URL:              "http://localhost:8080",
+			PasswordResetURL: "http://localhost:3000/reset-password",
+			RateLimitStore:   "memory",
+			UploadDir:        "uploads",
+		},
+		Database: Database{
+			Port:            "5432",
+			SSLMode:         "require",
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+		},
+		SMTP: SMTP{
+			Port: 587,
+		},
+		JWT: JWT{
+			AccessTokenTTL:  15 * time.Minute,
+			RefreshTokenTTL: 30 * 24 * time.Hour,
+			SigningAlg:      "RS256",
+		},
+		Workers: Workers{
+			Outbox:  4,
+			Webhook: 2,
+		},
+		Login: Login{
+			MaxFailures:     5,
+			LockoutDuration: 15 * time.Minute,
+			TOTPIssuer:      "Car Rental",
+		},
+		Rentals: Rentals{
+			WaitlistHold: 24 * time.Hour,
+			PickupLead:   24 * time.Hour,
+			ReturnLead:   24 * time.Hour,
+		},
+	}
+}
+
+func fromEnv() (*Config, error) {
+	r := &reader{}
+	d := Defaults()
+	cfg := &Config{
+		App: App{
+			Env:              r.str("APP_ENV", d.App.Env),
+			ListenAddr:       r.str("LISTEN_ADDR", d.App.ListenAddr),
+			ShutdownTimeout:  r.duration("SHUTDOWN_TIMEOUT", d.App.ShutdownTimeout),
+			ReadyTimeout:     r.duration("READY_CHECK_TIMEOUT", d.App.ReadyTimeout),
+			ReadyCacheTTL:    r.duration("READY_CACHE_TTL", d.App.ReadyCacheTTL),
+			URL:              strings.TrimSuffix(r.str("APP_URL", d.App.URL), "/"),
+			PasswordResetURL: r.str("PASSWORD_RESET_URL", d.App.PasswordResetURL),
+			RateLimitStore:   r.str("LOGIN_RATE_LIMIT_STORE", d.App.RateLimitStore),
+			TrustedProxies:   r.list("TRUSTED_PROXIES"),
+			MetricsToken:     Secret(r.str("METRICS_TOKEN", "")),
+			UploadDir:        r.str("UPLOAD_DIR", d.App.UploadDir),
+		},
+		Database: Database{
+			Host:            r.str("DB_HOST", ""),
+			Port:            r.str("DB_PORT", d.Database.Port),
+			User:            r.str("DB_USER", ""),
+			Password:        Secret(r.str("DB_PASSWORD", "")),
+			Name:            r.str("DB_NAME", ""),
+			SSLMode:         r.str("DB_SSLMODE", d.Database.SSLMode),
+			MaxOpenConns:    r.int("DB_MAX_OPEN_CONNS", d.Database.MaxOpenConns),
+			MaxIdleConns:    r.int("DB_MAX_IDLE_CONNS", d.Database.MaxIdleConns),
+			ConnMaxLifetime: r.duration("DB_CONN_MAX_LIFETIME", d.Database.ConnMaxLifetime),
+			ConnMaxIdleTime: r.duration("DB_CONN_MAX_IDLE_TIME", d.Database.ConnMaxIdleTime),
+			AutoMigrate:     r.bool("DB_AUTO_MIGRATE", false),
+		},
+		SMTP: SMTP{
+			Host:     r.str("SMTP_HOST", ""),
+			Port:     r.int("SMTP_PORT", d.SMTP.Port),
+			User:     r.str("SMTP_USER", ""),
+			Password: Secret(r.str("SMTP_PASSWORD", "")),
+			From:     r.str("SMTP_FROM", ""),
+		},
+		JWT: JWT{
+			Secret:          Secret(r.str("JWT_SECRET", "")),
+			AccessTokenTTL:  r.minutes("ACCESS_TOKEN_TTL_MINUTES", d.JWT.AccessTokenTTL),
+			RefreshTokenTTL: r.days("REFRESH_TOKEN_TTL_DAYS", d.JWT.RefreshTokenTTL),
+			KeyDir:          r.str("JWT_KEY_DIR", ""),
+			SigningAlg:      r.str("JWT_SIGNING_ALG", d.JWT.SigningAlg),
+			SigningKID:      r.str("JWT_SIGNING_KID", ""),
+			KeyRotation:     r.hours("JWT_KEY_ROTATION_HOURS", 0),
+		},
+		Xendit: Xendit{
+			SecretKey: Secret(r.str("XENDIT_SECRET_KEY", "")),
+		},
+		Workers: Workers{
+			Outbox:  r.int("OUTBOX_WORKERS", d.Workers.Outbox),
+			Webhook: r.int("WEBHOOK_WORKERS", d.Workers.Webhook),
+		},
+		OIDC: OIDC{
+			Issuer:       strings.TrimSuffix(r.str("OIDC_ISSUER", ""), "/"),
+			ClientID:     r.str("OIDC_CLIENT_ID", ""),
+			ClientSecret: Secret(r.str("OIDC_CLIENT_SECRET", "")),
+			RedirectURL:  r.str("OIDC_REDIRECT_URL", ""),
+			Mock:         r.bool("OIDC_MOCK", false),
+			MockEmail:    r.str("OIDC_MOCK_EMAIL", ""),
+		},
+		SMS: SMS{
+			ProviderURL: r.str("SMS_PROVIDER_URL", ""),
+			Token:       Secret(r.str("SMS_PROVIDER_TOKEN", "")),
+			Sender:      r.str("SMS_SENDER", ""),
+		},
+		Login: Login{
+			MaxFailures:     r.int("LOGIN_MAX_FAILURES", d.Login.MaxFailures),
+			LockoutDuration: r.minutes("LOGIN_LOCKOUT_MINUTES", d.Login.LockoutDuration),
+			TOTPIssuer:      r.str("TOTP_ISSUER", d.Login.TOTPIssuer),
+		},
+		Rentals: Rentals{
+			WaitlistHold: r.hours("WAITLIST_HOLD_HOURS", d.Rentals.WaitlistHold),
+			PickupLead:   r.hours("REMINDER_PICKUP_HOURS", d.Rentals.PickupLead),
+			ReturnLead:   r.hours("REMINDER_RETURN_HOURS", d.Rentals.ReturnLead),
+		},
+	}
+	if cfg.SMTP.From == "" {
+		cfg.SMTP.From = cfg.SMTP.User
+	}
+	if cfg.SMS.ProviderURL == "" && cfg.App.Env == "development" {
+		cfg.SMS.Stub = true
+	}
+
+	return cfg, errors.Join(r.errs...)
+}
+
+// Validate reports every invalid setting at once
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.App.Env == "development" || c.App.Env == "production", "APP_ENV must be development or production")
+	check(c.App.ListenAddr != "", "LISTEN_ADDR is required")
+	check(c.App.ShutdownTimeout > 0, "SHUTDOWN_TIMEOUT must be positive")
+	check(c.App.ReadyTimeout > 0, "READY_CHECK_TIMEOUT must be positive")
+	check(c.App.ReadyCacheTTL >= 0, "READY_CACHE_TTL cannot be negative")
+	check(absoluteURL(c.App.URL), "APP_URL must be an absolute URL")
+	check(absoluteURL(c.App.PasswordResetURL), "PASSWORD_RESET_URL must be an absolute URL")
+	check(c.App.RateLimitStore == "memory" || c.App.RateLimitStore == "database", "LOGIN_RATE_LIMIT_STORE must be memory or database")
+	check(c.App.UploadDir != "", "UPLOAD_DIR cannot be empty")
+	if _, err := c.App.TrustedProxyRanges(); err != nil {
+		errs = append(errs, err)
+	}
+
+	check(c.Database.Host != "", "DB_HOST is required")
+	check(c.Database.User != "", "DB_USER is required")
+	check(c.Database.Name != "", "DB_NAME is required")
+	check(sslModes[c.Database.SSLMode], "DB_SSLMODE %q is not a valid PostgreSQL sslmode", c.Database.SSLMode)
+	check(c.Database.MaxOpenConns >= 0, "DB_MAX_OPEN_CONNS cannot be negative")
+	check(c.Database.MaxIdleConns >= 0, "DB_MAX_IDLE_CONNS cannot be negative")
+	check(c.Database.MaxOpenConns == 0 || c.Database.MaxIdleConns <= c.Database.MaxOpenConns, "DB_MAX_IDLE_CONNS cannot exceed DB_MAX_OPEN_CONNS")
+	check(c.Database.ConnMaxLifetime >= 0, "DB_CONN_MAX_LIFETIME cannot be negative")
+	check(c.Database.ConnMaxIdleTime >= 0, "DB_CONN_MAX_IDLE_TIME cannot be negative")
+
+	check(c.SMTP.Port > 0 && c.SMTP.Port < 65536, "SMTP_PORT must be a valid port")
+
+	check(c.JWT.Secret != "", "JWT_SECRET is required")
+	check(c.JWT.AccessTokenTTL > 0, "ACCESS_TOKEN_TTL_MINUTES must be positive")
+	check(c.JWT.RefreshTokenTTL > 0, "REFRESH_TOKEN_TTL_DAYS must be positive")
+	check(c.JWT.SigningAlg == "RS256" || c.JWT.SigningAlg == "EdDSA", "JWT_SIGNING_ALG must be RS256 or EdDSA")
+	check(c.JWT.KeyRotation >= 0, "JWT_KEY_ROTATION_HOURS cannot be negative")
+
+	check(c.Workers.Outbox > 0, "OUTBOX_WORKERS must be positive")
+	check(c.Workers.Webhook > 0, "WEBHOOK_WORKERS must be positive")
+
+	// OIDC is all or nothing, a partial setup would fail at the first login
+	if c.OIDC.Issuer != "" || c.OIDC.ClientID != "" || c.OIDC.RedirectURL != "" || c.OIDC.Mock {
+		check(absoluteURL(c.OIDC.Issuer), "OIDC_ISSUER must be an absolute URL")
+		check(c.OIDC.ClientID != "", "OIDC_CLIENT_ID is required when OIDC is enabled")
+		check(absoluteURL(c.OIDC.RedirectURL), "OIDC_REDIRECT_URL must be an absolute URL")
+	}
+	if c.OIDC.Mock {
+		u, err := url.Parse(c.OIDC.Issuer)
+		check(err == nil && strings.Trim(u.Path, "/") != "", "OIDC_MOCK requires OIDC_ISSUER with a path, e.g. http://localhost:8080/mock-oidc")
+	}
+	check(c.SMS.ProviderURL == "" || absoluteURL(c.SMS.ProviderURL), "SMS_PROVIDER_URL must be an absolute URL")
+
+	check(c.Login.MaxFailures > 0, "LOGIN_MAX_FAILURES must be positive")
+	check(c.Login.LockoutDuration > 0, "LOGIN_LOCKOUT_MINUTES must be positive")
+	check(c.Login.TOTPIssuer != "", "TOTP_ISSUER cannot be empty")
+
+	check(c.Rentals.WaitlistHold > 0, "WAITLIST_HOLD_HOURS must be positive")
+	check(c.Rentals.PickupLead > 0, "REMINDER_PICKUP_HOURS must be positive")
+	check(c.Rentals.ReturnLead > 0, "REMINDER_RETURN_HOURS must be positive")
+
+	// Production must not run on development placeholders
+	if c.App.Env == "production" {
+		check(len(c.JWT.Secret) >= 32, "JWT_SECRET must be at least 32 characters in production")
+		check(c.Database.SSLMode != "disable", "DB_SSLMODE cannot be disable in production")
+		check(c.SMTP.Host != "", "SMTP_HOST is required in production")
+		check(c.Xendit.SecretKey != "", "XENDIT_SECRET_KEY is required in production")
+		check(!c.OIDC.Mock, "OIDC_MOCK signs in anyone and cannot be enabled in production")
+		check(!c.SMS.Stub, "the SMS stub only logs messages and cannot be used in production, set SMS_PROVIDER_URL")
+		check(c.App.PasswordResetURL != Defaults().App.PasswordResetURL, "PASSWORD_RESET_URL is required in production")
+		check(c.App.MetricsToken != "", "METRICS_TOKEN is required in production, /metrics would be open to anyone")
+	}
+
+	return errors.Join(errs...)
+}
+
+// TrustedProxyRanges parses TRUSTED_PROXIES, a bare IP is a range of one address
+func (a App) TrustedProxyRanges() ([]*net.IPNet, error) {
+	ranges := []*net.IPNet{}
+	for _, proxy := range a.TrustedProxies {
+		if ip := net.ParseIP(proxy); ip != nil {
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			ranges = append(ranges, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("TRUSTED_PROXIES entry %q is not an IP or CIDR range", proxy)
+		}
+		ranges = append(ranges, ipNet)
+	}
+	return ranges, nil
+}
+
+func absoluteURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+// DSN is the PostgreSQL connection string, it contains the password
+func (d Database) DSN() string {
+	return d.dsn(d.Password.Value())
+}
+
+// RedactedDSN is safe to log
+func (d Database) RedactedDSN() string {
+	return d.dsn(d.Password.String())
+}
+
+func (d Database) dsn(password string) string {
+	return fmt.Sprintf(
+		"host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
+		d.Host, d.User, quote(password), d.Name, d.Port, d.SSLMode,
+	)
+}
+
+// quote escapes a DSN value that may contain spaces or quotes
+func quote(value string) string {
+	if value != "" && !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
@@ -0,0 +1,197 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// valid returns a development config that passes Validate
+func valid() Config {
+	cfg := Defaults()
+	cfg.Database.Host = "localhost"
+	cfg.Database.User = "app"
+	cfg.Database.Name = "car_rental"
+	cfg.JWT.Secret = "development-secret"
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   string // substring of the error, empty when valid
+	}{
+		{"defaults", func(c *Config) {}, ""},
+		{"unknown env", func(c *Config) { c.App.Env = "staging" }, "APP_ENV"},
+		{"relative app url", func(c *Config) { c.App.URL = "/api" }, "APP_URL"},
+		{"relative reset url", func(c *Config) { c.App.PasswordResetURL = "/reset-password" }, "PASSWORD_RESET_URL"},
+		{"missing db host", func(c *Config) { c.Database.Host = "" }, "DB_HOST"},
+		{"bad sslmode", func(c *Config) { c.Database.SSLMode = "on" }, "DB_SSLMODE"},
+		{"idle above open", func(c *Config) { c.Database.MaxIdleConns = 50 }, "DB_MAX_IDLE_CONNS"},
+		{"missing jwt secret", func(c *Config) { c.JWT.Secret = "" }, "JWT_SECRET"},
+		{"bad signing alg", func(c *Config) { c.JWT.SigningAlg = "HS512" }, "JWT_SIGNING_ALG"},
+		{"partial oidc", func(c *Config) { c.OIDC.Issuer = "https://id.example.com" }, "OIDC_CLIENT_ID"},
+		{"complete oidc", func(c *Config) {
+			c.OIDC.Issuer = "https://id.example.com"
+			c.OIDC.ClientID = "car-rental"
+			c.OIDC.RedirectURL = "https://api.example.com/api/v1/oidc/callback"
+		}, ""},
+		{"mock without issuer path", func(c *Config) {
+			c.OIDC.Mock = true
+			c.OIDC.Issuer = "http://localhost:8080"
+			c.OIDC.ClientID = "car-rental"
+			c.OIDC.RedirectURL = "http://localhost:8080/api/v1/oidc/callback"
+		}, "OIDC_MOCK requires OIDC_ISSUER with a path"},
+		{"relative sms url", func(c *Config) { c.SMS.ProviderURL = "sms.local" }, "SMS_PROVIDER_URL"},
+		{"trusted proxies", func(c *Config) { c.App.TrustedProxies = []string{"10.0.0.0/8", "192.168.1.10", "fd00::/8"} }, ""},
+		{"bad trusted proxy", func(c *Config) { c.App.TrustedProxies = []string{"10.0.0.0/33"} }, "TRUSTED_PROXIES"},
+		{"zero login failures", func(c *Config) { c.Login.MaxFailures = 0 }, "LOGIN_MAX_FAILURES"},
+		{"zero waitlist hold", func(c *Config) { c.Rentals.WaitlistHold = 0 }, "WAITLIST_HOLD_HOURS"},
+		{"short production secret", func(c *Config) {
+			c.App.Env = "production"
+			c.SMTP.Host = "smtp.example.com"
+			c.Xendit.SecretKey = "xnd_production"
+		}, "at least 32 characters"},
+		{"mock provider in production", func(c *Config) {
+			c.App.Env = "production"
+			c.OIDC.Mock = true
+			c.OIDC.Issuer = "https://rental.example.com/mock-oidc"
+			c.OIDC.ClientID = "car-rental"
+			c.OIDC.RedirectURL = "https://rental.example.com/api/v1/oidc/callback"
+		}, "OIDC_MOCK"},
+		{"production without tls", func(c *Config) {
+			c.App.Env = "production"
+			c.Database.SSLMode = "disable"
+		}, "DB_SSLMODE cannot be disable"},
+		{"production without reset page", func(c *Config) { c.App.Env = "production" }, "PASSWORD_RESET_URL is required"},
+		{"open metrics in production", func(c *Config) { c.App.Env = "production" }, "METRICS_TOKEN is required"},
+		{"sms stub in production", func(c *Config) {
+			c.App.Env = "production"
+			c.SMS.Stub = true
+		}, "SMS stub"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.modify(&cfg)
+
+			err := cfg.Validate()
+			switch {
+			case tt.want == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.want != "" && err == nil:
+				t.Fatalf("expected an error containing %q", tt.want)
+			case tt.want != "" && !strings.Contains(err.Error(), tt.want):
+				t.Fatalf("error %q does not mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateReportsEveryError(t *testing.T) {
+	cfg := valid()
+	cfg.Database.Host = ""
+	cfg.JWT.Secret = ""
+	cfg.Workers.Outbox = 0
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected errors")
+	}
+	for _, want := range []string{"DB_HOST", "JWT_SECRET", "OUTBOX_WORKERS"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Setenv("DB_HOST", "db.internal")
+	t.Setenv("DB_USER", "app")
+	t.Setenv("DB_NAME", "car_rental")
+	t.Setenv("JWT_SECRET", "development-secret")
+	t.Setenv("APP_URL", "https://rental.example.com/")
+	t.Setenv("LOGIN_LOCKOUT_MINUTES", "30")
+	t.Setenv("WAITLIST_HOLD_HOURS", "6")
+	t.Setenv("REFRESH_TOKEN_TTL_DAYS", "7")
+
+	cfg, rest, err := Load([]string{"-config", "", "-addr", ":9090", "migrate", "status"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.App.ListenAddr != ":9090" {
+		t.Errorf("ListenAddr = %q, want the -addr flag", cfg.App.ListenAddr)
+	}
+	if cfg.App.URL != "https://rental.example.com" {
+		t.Errorf("URL = %q, want the trailing slash trimmed", cfg.App.URL)
+	}
+	if cfg.Login.LockoutDuration != 30*time.Minute {
+		t.Errorf("LockoutDuration = %v", cfg.Login.LockoutDuration)
+	}
+	if cfg.Rentals.WaitlistHold != 6*time.Hour {
+		t.Errorf("WaitlistHold = %v", cfg.Rentals.WaitlistHold)
+	}
+	if cfg.JWT.RefreshTokenTTL != 7*24*time.Hour {
+		t.Errorf("RefreshTokenTTL = %v", cfg.JWT.RefreshTokenTTL)
+	}
+	if !cfg.SMS.Stub {
+		t.Error("SMS.Stub = false, want the stub in development without SMS_PROVIDER_URL")
+	}
+	if cfg.Login.MaxFailures != 5 {
+		t.Errorf("MaxFailures = %d, want the default", cfg.Login.MaxFailures)
+	}
+	if strings.Join(rest, " ") != "migrate status" {
+		t.Errorf("remaining args = %q", rest)
+	}
+}
+
+func TestLoadRejectsMalformedValues(t *testing.T) {
+	t.Setenv("DB_HOST", "db.internal")
+	t.Setenv("DB_USER", "app")
+	t.Setenv("DB_NAME", "car_rental")
+	t.Setenv("JWT_SECRET", "development-secret")
+	t.Setenv("SMTP_PORT", "smtp")
+	t.Setenv("SHUTDOWN_TIMEOUT", "soon")
+
+	_, _, err := Load(nil)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{"SMTP_PORT", "SHUTDOWN_TIMEOUT"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+}
+
+func TestSecretIsRedacted(t *testing.T) {
+	cfg := valid()
+	cfg.Database.Password = "hunter2"
+	cfg.OIDC.ClientSecret = "oidc-secret"
+
+	encoded, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, out := range []string{fmt.Sprintf("%v", cfg), fmt.Sprintf("%+v", cfg), fmt.Sprintf("%#v", cfg), string(encoded), cfg.Database.RedactedDSN()} {
+		if strings.Contains(out, "hunter2") || strings.Contains(out, "oidc-secret") {
+			t.Errorf("secret leaked in %s", out)
+		}
+	}
+
+	if !strings.Contains(cfg.Database.DSN(), "password=hunter2") {
+		t.Errorf("DSN = %q, want the real password", cfg.Database.DSN())
+	}
+}
+
+func TestDSNQuotesPassword(t *testing.T) {
+	d := Database{Host: "localhost", User: "app", Name: "db", Port: "5432", SSLMode: "disable", Password: `p'a ss`}
+	if want := `password='p\'a ss'`; !strings.Contains(d.DSN(), want) {
+		t.Errorf("DSN = %q, want %s", d.DSN(), want)
+	}
+}
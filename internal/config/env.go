@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reader reads typed environment variables and collects parse errors
+type reader struct {
+	errs []error
+}
+
+func (r *reader) str(key, def string) string {
+	if value, ok := os.LookupEnv(key); ok && strings.TrimSpace(value) != "" {
+		return strings.TrimSpace(value)
+	}
+	return def
+}
+
+func (r *reader) int(key string, def int) int {
+	value := r.str(key, "")
+	if value == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s must be a whole number, got %q", key, value))
+		return def
+	}
+	return n
+}
+
+func (r *reader) bool(key string, def bool) bool {
+	value := r.str(key, "")
+	if value == "" {
+		return def
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s must be true or false, got %q", key, value))
+		return def
+	}
+	return b
+}
+
+// list reads a comma separated list, empty entries are dropped
+func (r *reader) list(key string) []string {
+	items := []string{}
+	for _, item := range strings.Split(r.str(key, ""), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// minutes reads a whole number of minutes
+func (r *reader) minutes(key string, def time.Duration) time.Duration {
+	return time.Duration(r.int(key, int(def/time.Minute))) * time.Minute
+}
+
+// hours reads a whole number of hours
+func (r *reader) hours(key string, def time.Duration) time.Duration {
+	return time.Duration(r.int(key, int(def/time.Hour))) * time.Hour
+}
+
+// days reads a whole number of days
+func (r *reader) days(key string, def time.Duration) time.Duration {
+	return time.Duration(r.int(key, int(def/(24*time.Hour)))) * 24 * time.Hour
+}
+
+// duration accepts Go durations such as 30s, 5m or 1h
+func (r *reader) duration(key string, def time.Duration) time.Duration {
+	value := r.str(key, "")
+	if value == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s must be a duration like 30s or 5m, got %q", key, value))
+		return def
+	}
+	return d
+}
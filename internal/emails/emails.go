@@ -0,0 +1,195 @@
+// Package emails renders notification emails from embedded templates.
+// Every template lives once per language in templates/<lang>/<name>.tmpl and
+// defines "subject", "content" (HTML, wrapped in the shared layout) and "text"
+// (plain-text alternative). An optional "summary" replaces the text in the
+// in-app notification list, for emails that carry secret links.
+package emails
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates
+var files embed.FS
+
+const DefaultLanguage = "en"
+
+// Languages lists the supported language codes
+var Languages = []string{"en", "id"}
+
+// Message is a rendered email
+type Message struct {
+	Subject string
+	HTML    string
+	Text    string
+	Summary string
+}
+
+// layoutStrings are the translated strings used by the shared layout
+var layoutStrings = map[string]map[string]string{
+	"en": {
+		"greeting": "Hello,",
+		"footer":   "You received this email because you have an account with Car Rental.",
+		"team":     "The Car Rental team",
+	},
+	"id": {
+		"greeting": "Halo,",
+		"footer":   "Anda menerima email ini karena memiliki akun di Car Rental.",
+		"team":     "Tim Car Rental",
+	},
+}
+
+// rupiah formats an amount as Rp1.234.567
+func rupiah(amount float64) string {
+	digits := fmt.Sprintf("%.0f", amount)
+	negative := strings.HasPrefix(digits, "-")
+	digits = strings.TrimPrefix(digits, "-")
+
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(d)
+	}
+
+	if negative {
+		return "-Rp" + b.String()
+	}
+	return "Rp" + b.String()
+}
+
+func funcs(lang string) map[string]interface{} {
+	return map[string]interface{}{
+		"rupiah": rupiah,
+		"t": func(key string) string {
+			return layoutStrings[lang][key]
+		},
+	}
+}
+
+// SupportedLanguage returns lang if templates exist for it, otherwise the default
+func SupportedLanguage(lang string) string {
+	for _, l := range Languages {
+		if l == lang {
+			return lang
+		}
+	}
+	return DefaultLanguage
+}
+
+// Names lists the available templates
+func Names() []string {
+	entries, err := files.ReadDir("templates/" + DefaultLanguage)
+	if err != nil {
+		return nil
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), ".tmpl"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render renders a template in the given language
+func Render(name, lang string, data map[string]interface{}) (*Message, error) {
+	lang = SupportedLanguage(lang)
+	path := fmt.Sprintf("templates/%s/%s.tmpl", lang, name)
+
+	source, err := files.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unknown email template %q", name)
+	}
+	layout, err := files.ReadFile("templates/layout.html")
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]interface{}{}
+	for k, v := range data {
+		values[k] = v
+	}
+	values["Lang"] = lang
+
+	// Subject, text and summary are plain text
+	textTmpl, err := texttemplate.New(name).Funcs(funcs(lang)).Parse(string(source))
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &Message{}
+	if msg.Subject, err = executeText(textTmpl, "subject", values); err != nil {
+		return nil, err
+	}
+	if msg.Text, err = executeText(textTmpl, "text", values); err != nil {
+		return nil, err
+	}
+	msg.Summary = msg.Text
+	if textTmpl.Lookup("summary") != nil {
+		if msg.Summary, err = executeText(textTmpl, "summary", values); err != nil {
+			return nil, err
+		}
+	}
+
+	// HTML body is the content wrapped in the layout
+	htmlTmpl, err := htmltemplate.New("layout").Funcs(funcs(lang)).Parse(string(layout))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := htmlTmpl.Parse(string(source)); err != nil {
+		return nil, err
+	}
+
+	var html bytes.Buffer
+	values["Subject"] = msg.Subject
+	if err := htmlTmpl.ExecuteTemplate(&html, "layout", values); err != nil {
+		return nil, err
+	}
+	msg.HTML = html.String()
+
+	return msg, nil
+}
+
+func executeText(tmpl *texttemplate.Template, name string, data interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// Sample returns example data for previewing a template
+func Sample(name string) map[string]interface{} {
+	samples := map[string]map[string]interface{}{
+		"account_locked":   {"Failures": 5, "URL": "https://example.com/api/v1/unlock-account?token=sample"},
+		"car_returned":     {"Car": "Toyota Avanza", "Date": "2026-10-19"},
+		"kyc_approved":     {},
+		"kyc_rejected":     {"Reason": "The licence photo is blurry"},
+		"login_alert":      {"Device": "Chrome on Windows", "IP": "203.0.113.10", "Time": "2026-10-19 08:30:00"},
+		"oidc_link":        {"Issuer": "https://accounts.example.com", "URL": "https://example.com/api/v1/oidc/link/confirm?token=sample"},
+		"partner_access":   {"Partner": "Bali Tours"},
+		"password_changed": {"Reset": false},
+		"password_reset":   {"URL": "https://example.com/reset-password?token=sample"},
+		"pickup_reminder":  {"Car": "Toyota Avanza", "Start": "2026-10-20"},
+		"rental_activated": {"Car": "Toyota Avanza", "Start": "2026-10-20", "End": "2026-10-23", "Amount": 1050000.0},
+		"rental_created":   {"Car": "Toyota Avanza", "Start": "2026-10-20", "End": "2026-10-23", "Amount": 1050000.0, "PaymentURL": "https://checkout.xendit.co/sample"},
+		"rental_overdue":   {"Car": "Toyota Avanza", "End": "2026-10-23", "Days": 2},
+		"return_reminder":  {"Car": "Toyota Avanza", "End": "2026-10-23"},
+		"topup":            {"Amount": 500000.0, "Balance": 1250000.0},
+		"verify_email":     {"URL": "https://example.com/api/v1/verify-email?token=sample"},
+		"waitlist_offer":   {"Car": "Honda Brio", "Start": "2026-10-20", "End": "2026-10-22", "HoldUntil": "2026-10-20 09:00:00"},
+		"welcome":          {},
+	}
+	if data, ok := samples[name]; ok {
+		return data
+	}
+	return map[string]interface{}{}
+}
@@ -0,0 +1,83 @@
+package emails
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderEveryTemplate(t *testing.T) {
+	names := Names()
+	if len(names) == 0 {
+		t.Fatal("no templates found")
+	}
+
+	for _, name := range names {
+		data := Sample(name)
+		rendered := map[string]*Message{}
+		for _, lang := range Languages {
+			msg, err := Render(name, lang, data)
+			if err != nil {
+				t.Errorf("%s/%s: %v", lang, name, err)
+				continue
+			}
+			rendered[lang] = msg
+
+			if msg.Subject == "" || msg.Text == "" || msg.Summary == "" || msg.HTML == "" {
+				t.Errorf("%s/%s: rendered an empty part: %+v", lang, name, msg)
+			}
+			for part, body := range map[string]string{"subject": msg.Subject, "text": msg.Text, "summary": msg.Summary, "html": msg.HTML} {
+				if strings.Contains(body, "<no value>") {
+					t.Errorf("%s/%s: %s uses data missing from Sample", lang, name, part)
+				}
+			}
+			if !strings.Contains(msg.HTML, layoutStrings[lang]["team"]) {
+				t.Errorf("%s/%s: HTML is not wrapped in the %s layout", lang, name, lang)
+			}
+
+			// Secret links go to the inbox only, never to the notification list
+			if url, ok := data["URL"].(string); ok && strings.Contains(msg.Summary, url) {
+				t.Errorf("%s/%s: summary contains the link", lang, name)
+			}
+		}
+
+		if en, id := rendered["en"], rendered["id"]; en != nil && id != nil && en.Subject == id.Subject {
+			t.Errorf("%s: subject %q is not translated", name, en.Subject)
+		}
+	}
+}
+
+func TestRenderFallsBackToDefaultLanguage(t *testing.T) {
+	want, err := Render("welcome", DefaultLanguage, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Render("welcome", "fr", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Subject != want.Subject {
+		t.Fatalf("subject for an unsupported language = %q, want %q", got.Subject, want.Subject)
+	}
+}
+
+func TestRenderUnknownTemplate(t *testing.T) {
+	if _, err := Render("does_not_exist", "en", nil); err == nil {
+		t.Fatal("rendered an unknown template")
+	}
+}
+
+func TestRupiah(t *testing.T) {
+	tests := map[float64]string{
+		0:        "Rp0",
+		999:      "Rp999",
+		1000:     "Rp1.000",
+		1050000:  "Rp1.050.000",
+		-250000:  "-Rp250.000",
+		123456.6: "Rp123.457",
+	}
+	for amount, want := range tests {
+		if got := rupiah(amount); got != want {
+			t.Errorf("rupiah(%v) = %s, want %s", amount, got, want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Package events is an in-process publish/subscribe bus for domain events.
+// Handlers publish events once their transaction has committed; subscribers
+// (analytics and metrics) run synchronously in the publisher's goroutine, in
+// subscription order. Writes that must not be lost, such as stock,
+// notifications and the audit log that feeds the per-user event streams,
+// happen inside the transaction instead.
+package events
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Event is something that happened to a user's account, rentals or payments
+type Event struct {
+	ID         uint64                 `json:"id"`
+	Type       string                 `json:"type"` // e.g. payment.updated, rental.activated
+	UserID     uint                   `json:"-"`
+	Data       map[string]interface{} `json:"data"`
+	OccurredAt time.Time              `json:"occurred_at"`
+}
+
+// Bus fans published events out to every subscriber
+type Bus struct {
+	mu          sync.RWMutex
+	nextSubID   int
+	subscribers map[int]func(Event)
+	sequence    uint64
+}
+
+func NewBus() *Bus {
+	return &Bus{subscribers: map[int]func(Event){}}
+}
+
+// Default is the bus shared by the application
+var Default = NewBus()
+
+// Subscribe registers fn for every event and returns a function that removes it
+func (b *Bus) Subscribe(fn func(Event)) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextSubID
+	b.nextSubID++
+	b.subscribers[id] = fn
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers, id)
+	}
+}
+
+// On registers fn for events of the given types only
+func (b *Bus) On(fn func(Event), eventTypes ...string) func() {
+	wanted := map[string]bool{}
+	for _, t := range eventTypes {
+		wanted[t] = true
+	}
+	return b.Subscribe(func(e Event) {
+		if wanted[e.Type] {
+			fn(e)
+		}
+	})
+}
+
+// Publish stamps the event and delivers it to all subscribers. Subscribers
+// may publish further events, a failing subscriber does not stop the others.
+func (b *Bus) Publish(e Event) {
+	e.ID = atomic.AddUint64(&b.sequence, 1)
+	if e.OccurredAt.IsZero() {
+		e.OccurredAt = time.Now()
+	}
+
+	b.mu.RLock()
+	ids := make([]int, 0, len(b.subscribers))
+	for id := range b.subscribers {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	subscribers := make([]func(Event), 0, len(ids))
+	for _, id := range ids {
+		subscribers = append(subscribers, b.subscribers[id])
+	}
+	b.mu.RUnlock()
+
+	for _, fn := range subscribers {
+		deliver(fn, e)
+	}
+}
+
+func deliver(fn func(Event), e Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Printf("Event subscriber panicked on %s: %v\n", e.Type, r)
+		}
+	}()
+	fn(e)
+}
+
+// New builds an event for a user
+func New(eventType string, userID uint, data map[string]interface{}) Event {
+	return Event{
+		Type:   eventType,
+		UserID: userID,
+		Data:   data,
+	}
+}
@@ -0,0 +1,46 @@
+package events
+
+// Domain event types
+const (
+	UserRegistered      = "user.registered"
+	EmailVerified       = "user.email_verified"
+	KYCReviewed         = "user.kyc_reviewed"
+	TopUpCompleted      = "deposit.topped_up"
+	RentalCreated       = "rental.created"
+	PaymentUpdated      = "payment.updated"
+	PaymentPaid         = "payment.paid"
+	RentalActivated     = "rental.activated"
+	CarReturned         = "rental.returned"
+	RentalCancelled     = "rental.cancelled"
+	NotificationCreated = "notification.created"
+)
+
+// Uint reads a numeric field from the event data
+func (e Event) Uint(key string) uint {
+	switch v := e.Data[key].(type) {
+	case uint:
+		return v
+	case int:
+		return uint(v)
+	case float64:
+		return uint(v)
+	}
+	return 0
+}
+
+// Float reads a decimal field from the event data
+func (e Event) Float(key string) float64 {
+	switch v := e.Data[key].(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	}
+	return 0
+}
+
+// String reads a text field from the event data
+func (e Event) String(key string) string {
+	s, _ := e.Data[key].(string)
+	return s
+}
@@ -1,12 +1,13 @@
 package handlers
 
 import (
+	"car-rental/internal/events"
 	"car-rental/internal/models"
 	"car-rental/internal/services"
-	"car-rental/pkg/database"
 	"github.com/labstack/echo/v4"
 	"golang.org/x/crypto/bcrypt"
 	"net/http"
+	"time"
 )
 
 type RegisterRequest struct {
@@ -19,12 +20,23 @@ type LoginRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
-func Register(c echo.Context) error {
+func (h *Handler) Register(c echo.Context) error {
 	var req RegisterRequest
 	if err := c.Bind(&req); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
+	req.Email = services.NormalizeEmail(req.Email)
+
+	// Older accounts may have been stored with mixed case
+	var existing int64
+	if err := h.DB.Model(&models.User{}).Where("LOWER(email) = ?", req.Email).Count(&existing).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to register")
+	}
+	if existing > 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Email already exists")
+	}
+
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -37,60 +49,142 @@ func Register(c echo.Context) error {
 		Password: string(hashedPassword),
 	}
 
-	if err := database.DB.Create(&user).Error; err != nil {
+	tx := h.DB.Begin()
+
+	if err := tx.Create(&user).Error; err != nil {
+		rollbackTx(tx)
 		return echo.NewHTTPError(http.StatusBadRequest, "Email already exists")
 	}
 
-	// Send welcome email
-	emailService := services.NewEmailService()
-	go emailService.SendEmail(
-		user.Email,
-		"Welcome to Car Rental System",
-		"Thank you for registering with our car rental service!",
-	)
+	// The verification email is sent by the notification subscriber
+	raiseEvent(tx, events.New(events.UserRegistered, user.ID, map[string]interface{}{
+		"user_id": user.ID,
+	}))
+
+	if err := h.commitTx(tx); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to register")
+	}
 
 	return c.JSON(http.StatusCreated, map[string]string{
-		"message": "Registration successful",
+		"message": "Registration successful, please verify your email",
 	})
 }
 
-func Login(c echo.Context) error {
+func (h *Handler) Login(c echo.Context) error {
 	var req LoginRequest
 	if err := c.Bind(&req); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
+	req.Email = services.NormalizeEmail(req.Email)
+
+	// Rate limit per IP and per account
+	ip := c.RealIP()
+	if wait, err := h.Limiter.CheckIP(ip); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check login attempts")
+	} else if wait > 0 {
+		return tooManyAttempts(c, wait)
+	}
+	if wait, err := h.Limiter.CheckAccount(req.Email); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check login attempts")
+	} else if wait > 0 {
+		return tooManyAttempts(c, wait)
+	}
+
 	// Find user
 	var user models.User
-	if err := database.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
+	if err := h.DB.Where("LOWER(email) = ?", req.Email).First(&user).Error; err != nil {
+		h.Limiter.RecordFailure(ip, req.Email)
+		h.recordLoginAttempt(c, req.Email, nil, false)
 		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid credentials")
 	}
 
+	// Check lockout
+	if user.LockedUntil != nil && time.Now().Before(*user.LockedUntil) {
+		h.recordLoginAttempt(c, req.Email, &user.ID, false)
+		return echo.NewHTTPError(http.StatusLocked, "Account is temporarily locked, check your email to unlock it")
+	}
+
 	// Check password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		h.Limiter.RecordFailure(ip, req.Email)
+		h.recordLoginAttempt(c, req.Email, &user.ID, false)
+		h.registerLoginFailure(&user)
 		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid credentials")
 	}
 
+	// Reset lockout counter
+	if user.FailedLoginCount > 0 || user.LockedUntil != nil {
+		h.DB.Model(&user).Updates(map[string]interface{}{
+			"failed_login_count": 0,
+			"locked_until":       nil,
+			"unlock_token_hash":  nil,
+		})
+	}
+
+	// Password is only the first step when two-factor authentication is enabled
+	if user.TOTPEnabledAt != nil {
+		challenge, err := services.GenerateTwoFactorChallenge(user.ID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate challenge")
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"two_factor_required": true,
+			"challenge_token":     challenge,
+		})
+	}
+
+	h.Limiter.ResetAccount(req.Email)
+	h.recordLoginAttempt(c, req.Email, &user.ID, true)
+
+	return h.completeLogin(c, user)
+}
+
+// completeLogin issues the token pair and notifies the user of the new login
+func (h *Handler) completeLogin(c echo.Context, user models.User) error {
+	tx := h.DB.Begin()
+
+	// Record the session for this device
+	session, err := createSession(tx, c, user.ID)
+	if err != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create session")
+	}
+
+	refreshToken, _, err := issueRefreshToken(tx, user.ID, session.ID)
+	if err != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate refresh token")
+	}
+
+	// Send login notification email
+	if err := h.notifyUser(tx, user, "login", "login_alert", map[string]interface{}{
+		"Device": session.Device,
+		"IP":     session.IPAddress,
+		"Time":   session.CreatedAt.Format("2006-01-02 15:04:05"),
+	}); err != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to queue login notification")
+	}
+
+	if err := h.commitTx(tx); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create session")
+	}
+
 	// Generate JWT token
-	token, err := services.GenerateJWT(user.ID)
+	token, err := services.GenerateJWT(user.ID, session.ID)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate token")
 	}
 
-	// Send login notification email
-	emailService := services.NewEmailService()
-	go emailService.SendEmail(
-		user.Email,
-		"New Login Detected",
-		"A new login was detected on your account.",
-	)
-
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"token": token,
-		"user": map[string]interface{}{
-			"id":             user.ID,
-			"email":          user.Email,
-			"deposit_amount": user.DepositAmount,
-		},
-	})
+	response := tokenResponse(token, refreshToken)
+	response["user"] = map[string]interface{}{
+		"id":             user.ID,
+		"email":          user.Email,
+		"deposit_amount": user.DepositAmount,
+		"verified":       user.VerifiedAt != nil,
+	}
+
+	return c.JSON(http.StatusOK, response)
 }
@@ -1,31 +1,22 @@
 package handlers
 
 import (
-	"car-rental/internal/models"
-	"car-rental/pkg/database"
+	"car-rental/internal/repository"
 	"github.com/labstack/echo/v4"
 	"net/http"
 )
 
 // GetCars handler
-func GetCars(c echo.Context) error {
-	var cars []models.Car
-
+func (h *Handler) GetCars(c echo.Context) error {
 	// Get query parameters
-	category := c.QueryParam("category")
-	available := c.QueryParam("available")
-
-	// Build query
-	query := database.DB
-	if category != "" {
-		query = query.Where("category = ?", category)
-	}
-	if available == "true" {
-		query = query.Where("stock_availability > ?", 0)
+	filter := repository.CarFilter{
+		Category:      c.QueryParam("category"),
+		AvailableOnly: c.QueryParam("available") == "true",
 	}
 
 	// Execute query
-	if err := query.Find(&cars).Error; err != nil {
+	cars, err := h.Cars.List(filter)
+	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch cars")
 	}
 
@@ -48,11 +39,14 @@ func GetCars(c echo.Context) error {
 }
 
 // GetCarDetail handler
-func GetCarDetail(c echo.Context) error {
-	carID := c.Param("id")
+func (h *Handler) GetCarDetail(c echo.Context) error {
+	carID, ok := paramID(c, "id")
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "Car not found")
+	}
 
-	var car models.Car
-	if err := database.DB.First(&car, carID).Error; err != nil {
+	car, err := h.Cars.FindByID(carID)
+	if err != nil {
 		return echo.NewHTTPError(http.StatusNotFound, "Car not found")
 	}
 
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"car-rental/internal/emails"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+// GetEmailTemplates handler
+func (h *Handler) GetEmailTemplates(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data":      emails.Names(),
+		"languages": emails.Languages,
+	})
+}
+
+// PreviewEmailTemplate handler renders a template with sample data
+func (h *Handler) PreviewEmailTemplate(c echo.Context) error {
+	lang := c.QueryParam("lang")
+	if lang == "" {
+		lang = emails.DefaultLanguage
+	}
+	if emails.SupportedLanguage(lang) != lang {
+		return echo.NewHTTPError(http.StatusBadRequest, "Unsupported language")
+	}
+
+	name := c.Param("name")
+	msg, err := emails.Render(name, lang, emails.Sample(name))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Template not found")
+	}
+
+	switch c.QueryParam("format") {
+	case "", "html":
+		return c.HTML(http.StatusOK, msg.HTML)
+	case "text":
+		return c.String(http.StatusOK, msg.Subject+"\n\n"+msg.Text)
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "Format must be html or text")
+	}
+}
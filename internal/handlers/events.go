@@ -0,0 +1,347 @@
+package handlers
+
+import (
+	"car-rental/internal/events"
+	"car-rental/internal/models"
+	"car-rental/internal/services"
+	"encoding/json"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	eventStreamBuffer    = 32
+	eventStreamHeartbeat = 25 * time.Second
+	eventStreamWindow    = 30 * time.Second // how long an audit log entry is read again
+)
+
+// EventStreamPoll is how often main runs PollEventStreams
+const EventStreamPoll = time.Second
+
+// streamsClosed is closed on shutdown to end every open event stream
+var (
+	streamsClosed    = make(chan struct{})
+	closeStreamsOnce sync.Once
+)
+
+// CloseEventStreams ends open event streams so the server can drain, clients
+// reopen them on another instance with a new token and their last event id
+func CloseEventStreams() {
+	closeStreamsOnce.Do(func() { close(streamsClosed) })
+}
+
+// pendingEvents holds events raised inside open transactions until they commit
+var pendingEvents = struct {
+	sync.Mutex
+	byTx map[*gorm.DB][]events.Event
+}{byTx: map[*gorm.DB][]events.Event{}}
+
+// txSubscriber reacts to an event inside the transaction that raised it, so
+// its writes commit or roll back together with the change that caused them
+type txSubscriber struct {
+	eventTypes map[string]bool // every event when empty
+	fn         func(tx *gorm.DB, e events.Event) error
+}
+
+// onTx registers fn to run in the raising transaction, just before it commits
+func (h *Handler) onTx(fn func(tx *gorm.DB, e events.Event) error, eventTypes ...string) {
+	wanted := map[string]bool{}
+	for _, t := range eventTypes {
+		wanted[t] = true
+	}
+	h.subscribers = append(h.subscribers, txSubscriber{eventTypes: wanted, fn: fn})
+}
+
+// raiseEvent queues an event that is handled by the transaction subscribers
+// and published on the bus only if tx commits
+func raiseEvent(tx *gorm.DB, e events.Event) {
+	if e.OccurredAt.IsZero() {
+		e.OccurredAt = time.Now()
+	}
+
+	pendingEvents.Lock()
+	defer pendingEvents.Unlock()
+	pendingEvents.byTx[tx] = append(pendingEvents.byTx[tx], e)
+}
+
+// takeEvents removes and returns the events raised in tx
+func takeEvents(tx *gorm.DB) []events.Event {
+	pendingEvents.Lock()
+	defer pendingEvents.Unlock()
+	raised := pendingEvents.byTx[tx]
+	delete(pendingEvents.byTx, tx)
+	return raised
+}
+
+// commitTx runs the transaction subscribers, commits tx and then publishes the events raised in it.
+// A failing subscriber rolls the whole transaction back.
+func (h *Handler) commitTx(tx *gorm.DB) error {
+	raised, err := h.handleInTx(tx)
+	if err != nil {
+		rollbackTx(tx)
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	for _, e := range raised {
+		h.Events.Publish(e)
+	}
+	return nil
+}
+
+// handleInTx runs the transaction subscribers until they stop raising new events
+func (h *Handler) handleInTx(tx *gorm.DB) ([]events.Event, error) {
+	var handled []events.Event
+	for {
+		batch := takeEvents(tx)
+		if len(batch) == 0 {
+			return handled, nil
+		}
+
+		for _, e := range batch {
+			for _, sub := range h.subscribers {
+				if len(sub.eventTypes) > 0 && !sub.eventTypes[e.Type] {
+					continue
+				}
+				if err := sub.fn(tx, e); err != nil {
+					takeEvents(tx)
+					return nil, fmt.Errorf("handle %s: %w", e.Type, err)
+				}
+			}
+		}
+		handled = append(handled, batch...)
+	}
+}
+
+// rollbackTx rolls tx back and drops the events raised in it
+func rollbackTx(tx *gorm.DB) {
+	tx.Rollback()
+	takeEvents(tx)
+}
+
+// eventStreams are the event streams open on this instance, by user. Every
+// instance polls the shared audit log, so a stream sees events committed by
+// any instance, and the audit log id is the SSE event id.
+type eventStreams struct {
+	mu     sync.Mutex
+	byUser map[uint]map[*eventStream]bool
+}
+
+// eventStream is one open stream, it delivers each audit log entry once
+type eventStream struct {
+	userID uint
+	after  uint          // the client has seen every entry up to this id
+	sent   map[uint]bool // entries after it that were already delivered
+	ch     chan events.Event
+}
+
+func newEventStreams() *eventStreams {
+	return &eventStreams{byUser: map[uint]map[*eventStream]bool{}}
+}
+
+// open registers a stream for the user that starts after the given audit log id
+func (s *eventStreams) open(userID, after uint) (*eventStream, func()) {
+	stream := &eventStream{
+		userID: userID,
+		after:  after,
+		sent:   map[uint]bool{},
+		ch:     make(chan events.Event, eventStreamBuffer),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.byUser[userID] == nil {
+		s.byUser[userID] = map[*eventStream]bool{}
+	}
+	s.byUser[userID][stream] = true
+
+	return stream, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.byUser[userID], stream)
+		if len(s.byUser[userID]) == 0 {
+			delete(s.byUser, userID)
+		}
+	}
+}
+
+// users lists the users with an open stream
+func (s *eventStreams) users() []uint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	users := make([]uint, 0, len(s.byUser))
+	for userID := range s.byUser {
+		users = append(users, userID)
+	}
+	return users
+}
+
+// deliver hands an audit log entry to the user's streams that have not had it.
+// It never blocks, an entry that does not fit is offered again on the next poll.
+func (s *eventStreams) deliver(entry models.AuditLog) {
+	if entry.UserID == nil {
+		return
+	}
+
+	var data map[string]interface{}
+	json.Unmarshal([]byte(entry.Data), &data)
+	e := events.Event{
+		ID:         uint64(entry.ID),
+		Type:       entry.EventType,
+		UserID:     *entry.UserID,
+		Data:       data,
+		OccurredAt: entry.OccurredAt,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for stream := range s.byUser[*entry.UserID] {
+		if entry.ID <= stream.after || stream.sent[entry.ID] {
+			continue
+		}
+		select {
+		case stream.ch <- e:
+			stream.sent[entry.ID] = true
+		default:
+		}
+	}
+}
+
+// PollEventStreams delivers recent audit log entries to the streams open on
+// this instance. Entries are read again for eventStreamWindow, so one whose
+// transaction committed after a later id was already seen still arrives.
+func (h *Handler) PollEventStreams() {
+	users := h.streams.users()
+	if len(users) == 0 {
+		return
+	}
+
+	var entries []models.AuditLog
+	if err := h.DB.
+		Where("user_id IN ? AND created_at > ?", users, time.Now().Add(-eventStreamWindow)).
+		Order("id ASC").
+		Find(&entries).Error; err != nil {
+		fmt.Printf("Error polling event streams: %v\n", err)
+		return
+	}
+	for _, entry := range entries {
+		h.streams.deliver(entry)
+	}
+}
+
+// CreateEventStreamToken handler issues the short-lived token that opens an
+// event stream, EventSource cannot send the Authorization header
+func (h *Handler) CreateEventStreamToken(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+
+	token, err := services.GenerateEventStreamToken(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create stream token")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"token":      token,
+		"expires_in": int(services.EventStreamTokenTTL.Seconds()),
+	})
+}
+
+// lastEventID returns the last event the client saw, from the Last-Event-ID
+// header EventSource sends on reconnect or the last_event_id query parameter
+// clients pass when they reopen the stream with a new token
+func lastEventID(c echo.Context) (uint, bool) {
+	raw := c.Request().Header.Get("Last-Event-ID")
+	if raw == "" {
+		raw = c.QueryParam("last_event_id")
+	}
+	id, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// StreamEvents handler streams the user's events as Server-Sent Events. It is
+// opened with a stream token and ends after an access token lifetime, clients
+// then fetch a new token and resume from the last event id.
+func (h *Handler) StreamEvents(c echo.Context) error {
+	userID, err := services.ParseEventStreamToken(c.QueryParam("token"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired stream token")
+	}
+
+	// Resume after the client's last event, or start from now
+	after, resume := lastEventID(c)
+	if !resume {
+		var latest *uint
+		if err := h.DB.Model(&models.AuditLog{}).Where("user_id = ?", userID).
+			Select("MAX(id)").Scan(&latest).Error; err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to open event stream")
+		}
+		if latest != nil {
+			after = *latest
+		}
+	}
+
+	stream, closeStream := h.streams.open(userID, after)
+	defer closeStream()
+
+	// Replay what the client missed, a longer gap continues on the next reconnect
+	if resume {
+		var missed []models.AuditLog
+		if err := h.DB.Where("user_id = ? AND id > ?", userID, after).
+			Order("id ASC").Limit(eventStreamBuffer).
+			Find(&missed).Error; err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to open event stream")
+		}
+		for _, entry := range missed {
+			h.streams.deliver(entry)
+		}
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+
+	fmt.Fprint(res, "retry: 5000\n\n")
+	res.Flush()
+
+	heartbeat := time.NewTicker(eventStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	expired := time.NewTimer(services.AccessTokenTTL())
+	defer expired.Stop()
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case <-streamsClosed:
+			return nil
+		case <-expired.C:
+			fmt.Fprint(res, "event: token.expired\ndata: {}\n\n")
+			res.Flush()
+			return nil
+		case <-heartbeat.C:
+			fmt.Fprint(res, ": ping\n\n")
+			res.Flush()
+		case e := <-stream.ch:
+			data, err := json.Marshal(e)
+			if err != nil {
+				fmt.Printf("Error encoding event %d: %v\n", e.ID, err)
+				continue
+			}
+			fmt.Fprintf(res, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+			res.Flush()
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"car-rental/internal/events"
+	"car-rental/internal/models"
+	"car-rental/pkg/database"
+	"errors"
+	"gorm.io/gorm"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHandleInTxRunsSubscribersUntilQuiet(t *testing.T) {
+	h := &Handler{}
+	tx := &gorm.DB{}
+
+	var seen []string
+	h.onTx(func(tx *gorm.DB, e events.Event) error {
+		// A subscriber may raise follow-up events in the same transaction
+		raiseEvent(tx, events.New(events.PaymentPaid, e.UserID, nil))
+		return nil
+	}, events.RentalCreated)
+	h.onTx(func(tx *gorm.DB, e events.Event) error {
+		seen = append(seen, e.Type)
+		return nil
+	})
+
+	raiseEvent(tx, events.New(events.RentalCreated, 7, nil))
+	handled, err := h.handleInTx(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{events.RentalCreated, events.PaymentPaid}
+	if len(handled) != len(want) || len(seen) != len(want) {
+		t.Fatalf("handled %v and seen %v, want %v", handled, seen, want)
+	}
+	for i, eventType := range want {
+		if handled[i].Type != eventType || seen[i] != eventType {
+			t.Fatalf("handled %v and seen %v, want %v", handled, seen, want)
+		}
+		if handled[i].OccurredAt.IsZero() {
+			t.Fatalf("%s has no occurred_at", handled[i].Type)
+		}
+	}
+	if pending := takeEvents(tx); len(pending) != 0 {
+		t.Fatalf("%d events left pending after handling", len(pending))
+	}
+}
+
+func TestHandleInTxStopsOnSubscriberFailure(t *testing.T) {
+	h := &Handler{}
+	tx := &gorm.DB{}
+	failure := errors.New("stock update failed")
+
+	ran := 0
+	h.onTx(func(tx *gorm.DB, e events.Event) error {
+		return failure
+	}, events.RentalCreated)
+	h.onTx(func(tx *gorm.DB, e events.Event) error {
+		ran++
+		return nil
+	}, events.RentalCreated)
+
+	raiseEvent(tx, events.New(events.RentalCreated, 7, nil))
+	raiseEvent(tx, events.New(events.PaymentPaid, 7, nil))
+	handled, err := h.handleInTx(tx)
+	if !errors.Is(err, failure) {
+		t.Fatalf("err = %v, want the subscriber failure", err)
+	}
+	if handled != nil {
+		t.Fatalf("handled %v, want nothing to publish", handled)
+	}
+	if ran != 0 {
+		t.Fatalf("later subscriber ran %d times after the failure", ran)
+	}
+	// Nothing raised in the failed transaction may leak into a later publish
+	if pending := takeEvents(tx); len(pending) != 0 {
+		t.Fatalf("%d events left pending after the failure", len(pending))
+	}
+}
+
+func auditEntry(id, userID uint) models.AuditLog {
+	return models.AuditLog{ID: id, EventType: events.PaymentPaid, UserID: &userID, Data: `{"rental_id":1}`, OccurredAt: time.Now()}
+}
+
+// received drains the events waiting on a stream
+func received(stream *eventStream) []uint64 {
+	var ids []uint64
+	for {
+		select {
+		case e := <-stream.ch:
+			ids = append(ids, e.ID)
+		default:
+			return ids
+		}
+	}
+}
+
+func TestEventStreamsDeliverEachEntryOnce(t *testing.T) {
+	streams := newEventStreams()
+	stream, closeStream := streams.open(1, 5)
+
+	for _, entry := range []models.AuditLog{
+		auditEntry(4, 1), // seen before the client reconnected
+		auditEntry(7, 1),
+		auditEntry(6, 1), // committed after 7
+		auditEntry(7, 1), // read again by the next poll
+		auditEntry(8, 2), // another user
+	} {
+		streams.deliver(entry)
+	}
+
+	got := received(stream)
+	if len(got) != 2 || got[0] != 7 || got[1] != 6 {
+		t.Fatalf("delivered %v, want [7 6]", got)
+	}
+
+	closeStream()
+	if users := streams.users(); len(users) != 0 {
+		t.Errorf("users after close = %v, want none", users)
+	}
+}
+
+func TestEventStreamsRetryWhenFull(t *testing.T) {
+	streams := newEventStreams()
+	stream, closeStream := streams.open(1, 0)
+	defer closeStream()
+
+	for id := uint(1); id <= eventStreamBuffer+1; id++ {
+		streams.deliver(auditEntry(id, 1))
+	}
+	if got := received(stream); len(got) != eventStreamBuffer {
+		t.Fatalf("delivered %d events, want a full buffer of %d", len(got), eventStreamBuffer)
+	}
+
+	// The entry that did not fit arrives with the next poll
+	streams.deliver(auditEntry(eventStreamBuffer+1, 1))
+	if got := received(stream); len(got) != 1 || got[0] != eventStreamBuffer+1 {
+		t.Fatalf("delivered %v on the next poll, want the dropped entry", got)
+	}
+}
+
+func TestStreamEventsRequiresToken(t *testing.T) {
+	h, _ := newMemoryHandler()
+	expectStatus(t, request(t, h.StreamEvents, http.MethodGet, ""), http.StatusUnauthorized)
+}
+
+func TestPollEventStreams(t *testing.T) {
+	h := useTestDB(t)
+	user := createTestUser(t)
+	stream, closeStream := h.streams.open(user.ID, 0)
+	defer closeStream()
+
+	entry := auditEntry(0, user.ID)
+	if err := database.DB.Create(&entry).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	h.PollEventStreams()
+	h.PollEventStreams()
+
+	if got := received(stream); len(got) != 1 || got[0] != uint64(entry.ID) {
+		t.Fatalf("delivered %v, want audit entry %d once", got, entry.ID)
+	}
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"car-rental/internal/events"
+	"car-rental/internal/health"
+	"car-rental/internal/repository"
+	"car-rental/internal/services"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"strconv"
+)
+
+// Handler carries the dependencies of every handler and background job.
+// It is wired once in main, unit tests build it on repository.NewMemory.
+type Handler struct {
+	DB             *gorm.DB // transactions and the queries not yet behind a repository
+	Users          repository.UserRepository
+	Cars           repository.CarRepository
+	Rentals        repository.RentalRepository
+	Payments       repository.PaymentRepository
+	Notifications  repository.NotificationRepository
+	Waitlist       repository.WaitlistRepository
+	Email          services.Mailer
+	Notifiers      map[string]services.Notifier // available channels, keyed by channel
+	PaymentGateway services.PaymentGateway
+	Limiter        *services.LoginLimiter
+	Ready          *health.Checker // nil until main registers the dependency checks
+	Events         *events.Bus
+
+	subscribers []txSubscriber
+	streams     *eventStreams
+}
+
+// NewHandler builds a Handler on the given repositories. The login limiter
+// keeps its counters in memory until main swaps in a shared store.
+func NewHandler(db *gorm.DB, repos *repository.Repositories, email services.Mailer, paymentGateway services.PaymentGateway) *Handler {
+	return &Handler{
+		DB:             db,
+		Users:          repos.Users,
+		Cars:           repos.Cars,
+		Rentals:        repos.Rentals,
+		Payments:       repos.Payments,
+		Notifications:  repos.Notifications,
+		Waitlist:       repos.Waitlist,
+		Email:          email,
+		Notifiers:      services.NewNotifiers(email, settings.SMS),
+		PaymentGateway: paymentGateway,
+		Limiter:        services.NewLoginLimiter(services.NewMemoryRateLimitStore()),
+		Events:         events.Default,
+		streams:        newEventStreams(),
+	}
+}
+
+// channelAvailable reports whether this deployment can deliver on channel
+func (h *Handler) channelAvailable(channel string) bool {
+	_, ok := h.Notifiers[channel]
+	return ok
+}
+
+// availableChannels lists the channels users can choose, in services.Channels order
+func (h *Handler) availableChannels() []string {
+	available := []string{}
+	for _, channel := range services.Channels {
+		if h.channelAvailable(channel) {
+			available = append(available, channel)
+		}
+	}
+	return available
+}
+
+// paramID parses a numeric path parameter, ok is false when it is not a valid ID
+func paramID(c echo.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil || id == 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
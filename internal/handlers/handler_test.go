@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"car-rental/internal/models"
+	"car-rental/internal/repository"
+	"encoding/json"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newMemoryHandler returns a Handler on in-memory repositories, for handlers that need no transaction
+func newMemoryHandler() (*Handler, *repository.Memory) {
+	memory := repository.NewMemory()
+	return NewHandler(nil, memory.Repositories(), &testMailer{}, nil), memory
+}
+
+// userRequest runs handler as the given user with the :id path parameter set to id
+func userRequest(t *testing.T, handler echo.HandlerFunc, userID uint, method string, id uint, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	e := echo.New()
+	req := httptest.NewRequest(method, "/", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+
+	c := e.NewContext(req, rec)
+	c.Set("userID", userID)
+	c.SetParamNames("id")
+	c.SetParamValues(fmt.Sprint(id))
+	if err := handler(c); err != nil {
+		e.HTTPErrorHandler(err, c)
+	}
+	return rec
+}
+
+func TestGetPaymentDetailHidesOtherUsersPayments(t *testing.T) {
+	h, memory := newMemoryHandler()
+	owner := memory.AddUser(models.User{Email: "owner@example.com"})
+	other := memory.AddUser(models.User{Email: "other@example.com"})
+	car := memory.AddCar(models.Car{Name: "Avanza", StockAvailability: 1, RentalCosts: 300000})
+	rental := memory.AddRental(models.RentalHistory{UserID: owner.ID, CarID: car.ID, Status: "pending"})
+	payment := memory.AddPayment(models.Payment{RentalID: rental.ID, Amount: 300000, Status: "PENDING"})
+
+	expectStatus(t, userRequest(t, h.GetPaymentDetail, owner.ID, http.MethodGet, payment.ID, ""), http.StatusOK)
+	expectStatus(t, userRequest(t, h.GetPaymentDetail, other.ID, http.MethodGet, payment.ID, ""), http.StatusNotFound)
+}
+
+func TestJoinWaitlistCountsHolds(t *testing.T) {
+	h, memory := newMemoryHandler()
+	holder := memory.AddUser(models.User{Email: "holder@example.com"})
+	user := memory.AddUser(models.User{Email: "user@example.com"})
+	car := memory.AddCar(models.Car{Name: "Xenia", StockAvailability: 1, RentalCosts: 250000})
+	body := `{"rental_start":"2026-11-01","rental_end":"2026-11-03"}`
+
+	// A free unit is booked directly
+	expectStatus(t, userRequest(t, h.JoinWaitlist, user.ID, http.MethodPost, car.ID, body), http.StatusBadRequest)
+
+	// Held for someone else, the unit is gone and the user queues
+	expiresAt := time.Now().Add(time.Hour)
+	memory.AddWaitlistEntry(models.CarWaitlist{UserID: holder.ID, CarID: car.ID, Status: "offered", HoldExpiresAt: &expiresAt})
+
+	rec := userRequest(t, h.JoinWaitlist, user.ID, http.MethodPost, car.ID, body)
+	expectStatus(t, rec, http.StatusCreated)
+	var response struct {
+		Position int64 `json:"position"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	if response.Position != 1 {
+		t.Errorf("position = %d, want 1", response.Position)
+	}
+
+	expectStatus(t, userRequest(t, h.JoinWaitlist, user.ID, http.MethodPost, car.ID, body), http.StatusBadRequest)
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"car-rental/internal/buildinfo"
+	"car-rental/internal/health"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+// Healthz handler reports that the process is alive, it never touches dependencies
+func (h *Handler) Healthz(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status": "ok",
+	})
+}
+
+// Readyz handler reports whether the instance can serve traffic
+func (h *Handler) Readyz(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "no-store")
+	if h.Ready == nil {
+		return c.JSON(http.StatusServiceUnavailable, health.Report{Ready: false, Checks: []health.Result{}})
+	}
+
+	report := h.Ready.Report(c.Request().Context())
+	status := http.StatusOK
+	if !report.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	return c.JSON(status, report)
+}
+
+// Version handler returns the build metadata
+func (h *Handler) Version(c echo.Context) error {
+	return c.JSON(http.StatusOK, buildinfo.Get())
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"car-rental/internal/services"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+// GetJWKS handler
+func (h *Handler) GetJWKS(c echo.Context) error {
+	keys := []services.JWK{}
+	if services.Keys != nil {
+		keys = services.Keys.JWKS()
+	}
+
+	c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"keys": keys,
+	})
+}
@@ -0,0 +1,386 @@
+package handlers
+
+import (
+	"car-rental/internal/emails"
+	"car-rental/internal/events"
+	"car-rental/internal/models"
+	"car-rental/internal/services"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const maxKYCDocumentSize = 5 << 20 // 5 MB
+
+var kycDocumentTypes = map[string]bool{
+	"license_front": true,
+	"license_back":  true,
+	"selfie":        true,
+}
+
+var kycContentTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+}
+
+type UpdateProfileRequest struct {
+	FullName      string `json:"full_name"`
+	Phone         string `json:"phone"`
+	DateOfBirth   string `json:"date_of_birth"`
+	Address       string `json:"address"`
+	LicenseNumber string `json:"license_number"`
+	LicenseExpiry string `json:"license_expiry"`
+	Language      string `json:"language"`
+}
+
+type RejectKYCRequest struct {
+	Reason string `json:"reason" validate:"required"`
+}
+
+// formatDate formats an optional date as YYYY-MM-DD
+func formatDate(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.Format("2006-01-02")
+}
+
+// parseOptionalDate parses YYYY-MM-DD, an empty string means no change
+func parseOptionalDate(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
+// formatKYCProfile builds the profile and KYC part of user responses
+func formatKYCProfile(user models.User) map[string]interface{} {
+	return map[string]interface{}{
+		"full_name":         user.FullName,
+		"phone":             user.Phone,
+		"date_of_birth":     formatDate(user.DateOfBirth),
+		"address":           user.Address,
+		"language":          user.Language,
+		"license_number":    user.LicenseNumber,
+		"license_expiry":    formatDate(user.LicenseExpiry),
+		"kyc_status":        user.KYCStatus,
+		"kyc_reject_reason": user.KYCRejectReason,
+	}
+}
+
+// checkRentalKYC requires an approved licence that is valid until the rental ends
+func checkRentalKYC(user models.User, rentalEnd time.Time) error {
+	if user.KYCStatus != "approved" {
+		return echo.NewHTTPError(http.StatusForbidden, "Driver's licence verification is required before renting")
+	}
+	if user.LicenseExpiry == nil || user.LicenseExpiry.Before(rentalEnd) {
+		return echo.NewHTTPError(http.StatusForbidden, "Driver's licence expires before the rental ends")
+	}
+	return nil
+}
+
+// UpdateProfile handler
+func (h *Handler) UpdateProfile(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+
+	var req UpdateProfileRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	var user models.User
+	if err := h.DB.First(&user, userID).Error; err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+
+	dateOfBirth, err := parseOptionalDate(req.DateOfBirth)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid date of birth format. Use YYYY-MM-DD")
+	}
+	licenseExpiry, err := parseOptionalDate(req.LicenseExpiry)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid licence expiry format. Use YYYY-MM-DD")
+	}
+
+	if req.Language != "" && emails.SupportedLanguage(req.Language) != req.Language {
+		return echo.NewHTTPError(http.StatusBadRequest, "Language must be one of: "+strings.Join(emails.Languages, ", "))
+	}
+
+	updates := map[string]interface{}{}
+	if req.Language != "" {
+		updates["language"] = req.Language
+	}
+	if req.FullName != "" {
+		updates["full_name"] = req.FullName
+	}
+	if req.Phone != "" {
+		updates["phone"] = req.Phone
+	}
+	if req.Address != "" {
+		updates["address"] = req.Address
+	}
+	if dateOfBirth != nil {
+		updates["date_of_birth"] = *dateOfBirth
+	}
+
+	// Identity or licence changes invalidate an earlier review
+	identityChanged := false
+	if req.FullName != "" && req.FullName != user.FullName {
+		identityChanged = true
+	}
+	if dateOfBirth != nil && (user.DateOfBirth == nil || !dateOfBirth.Equal(*user.DateOfBirth)) {
+		identityChanged = true
+	}
+	if req.LicenseNumber != "" {
+		updates["license_number"] = req.LicenseNumber
+		identityChanged = identityChanged || req.LicenseNumber != user.LicenseNumber
+	}
+	if licenseExpiry != nil {
+		updates["license_expiry"] = *licenseExpiry
+		identityChanged = identityChanged || user.LicenseExpiry == nil || !licenseExpiry.Equal(*user.LicenseExpiry)
+	}
+	if identityChanged && user.KYCStatus != "none" {
+		updates["kyc_status"] = "none"
+		updates["kyc_reviewed_at"] = nil
+		updates["kyc_reject_reason"] = ""
+	}
+
+	if len(updates) > 0 {
+		if err := h.DB.Model(&user).Updates(updates).Error; err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update profile")
+		}
+		h.DB.First(&user, userID)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Profile updated",
+		"profile": formatKYCProfile(user),
+	})
+}
+
+// UploadKYCDocument handler
+func (h *Handler) UploadKYCDocument(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+
+	docType := c.FormValue("type")
+	if !kycDocumentTypes[docType] {
+		return echo.NewHTTPError(http.StatusBadRequest, "Document type must be license_front, license_back or selfie")
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "File is required")
+	}
+	if file.Size > maxKYCDocumentSize {
+		return echo.NewHTTPError(http.StatusBadRequest, "File must be 5 MB or smaller")
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Failed to read file")
+	}
+	defer src.Close()
+
+	// Detect the type from content, not from the client's header
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(src, head)
+	contentType := http.DetectContentType(head[:n])
+	ext, ok := kycContentTypes[contentType]
+	if !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, "Only JPEG and PNG images are accepted")
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to read file")
+	}
+
+	name, err := services.RandomToken(16)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to store file")
+	}
+
+	dir := filepath.Join(settings.App.UploadDir, "kyc", fmt.Sprintf("%d", userID))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to store file")
+	}
+
+	path := filepath.Join(dir, name+ext)
+	dst, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to store file")
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, io.LimitReader(src, maxKYCDocumentSize)); err != nil {
+		os.Remove(path)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to store file")
+	}
+
+	document := models.KYCDocument{
+		UserID:      userID,
+		Type:        docType,
+		FilePath:    path,
+		ContentType: contentType,
+	}
+	if err := h.DB.Create(&document).Error; err != nil {
+		os.Remove(path)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save document")
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"message": "Document uploaded",
+		"data":    document,
+	})
+}
+
+// SubmitKYC handler
+func (h *Handler) SubmitKYC(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+
+	var user models.User
+	if err := h.DB.First(&user, userID).Error; err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+
+	if user.KYCStatus == "pending" || user.KYCStatus == "approved" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Verification is already "+user.KYCStatus)
+	}
+
+	if user.FullName == "" || user.Phone == "" || user.DateOfBirth == nil || user.Address == "" ||
+		user.LicenseNumber == "" || user.LicenseExpiry == nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Complete your profile and licence details first")
+	}
+	if user.LicenseExpiry.Before(time.Now()) {
+		return echo.NewHTTPError(http.StatusBadRequest, "Driver's licence has expired")
+	}
+
+	var documents int64
+	h.DB.Model(&models.KYCDocument{}).
+		Where("user_id = ? AND type = ?", userID, "license_front").
+		Count(&documents)
+	if documents == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Upload a photo of the front of your licence first")
+	}
+
+	if err := h.DB.Model(&user).Updates(map[string]interface{}{
+		"kyc_status":        "pending",
+		"kyc_reject_reason": "",
+	}).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to submit verification")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Verification submitted for review",
+	})
+}
+
+// GetKYCQueue handler
+func (h *Handler) GetKYCQueue(c echo.Context) error {
+	status := c.QueryParam("status")
+	if status == "" {
+		status = "pending"
+	}
+
+	var users []models.User
+	if err := h.DB.Where("kyc_status = ?", status).Order("updated_at ASC").Find(&users).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch verification queue")
+	}
+
+	queue := []map[string]interface{}{}
+	for _, user := range users {
+		var documents []models.KYCDocument
+		h.DB.Where("user_id = ?", user.ID).Order("created_at DESC").Find(&documents)
+
+		entry := formatKYCProfile(user)
+		entry["user_id"] = user.ID
+		entry["email"] = user.Email
+		entry["documents"] = documents
+		queue = append(queue, entry)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": queue,
+	})
+}
+
+// GetKYCDocument handler
+func (h *Handler) GetKYCDocument(c echo.Context) error {
+	documentID := c.Param("id")
+
+	var document models.KYCDocument
+	if err := h.DB.First(&document, documentID).Error; err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Document not found")
+	}
+
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.File(document.FilePath)
+}
+
+// ApproveKYC handler
+func (h *Handler) ApproveKYC(c echo.Context) error {
+	return h.reviewKYC(c, "approved", "")
+}
+
+// RejectKYC handler
+func (h *Handler) RejectKYC(c echo.Context) error {
+	var req RejectKYCRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if req.Reason == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Reason is required")
+	}
+	return h.reviewKYC(c, "rejected", req.Reason)
+}
+
+// reviewKYC records the admin decision on a pending verification
+func (h *Handler) reviewKYC(c echo.Context, status, reason string) error {
+	userID := c.Param("id")
+
+	var user models.User
+	if err := h.DB.First(&user, userID).Error; err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+
+	if user.KYCStatus != "pending" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Verification is not pending")
+	}
+
+	tx := h.DB.Begin()
+
+	// Only one of two admins reviewing at the same time gets to decide
+	result := tx.Model(&user).Where("kyc_status = ?", "pending").Updates(map[string]interface{}{
+		"kyc_status":        status,
+		"kyc_reviewed_at":   time.Now(),
+		"kyc_reject_reason": reason,
+	})
+	if result.Error != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save review")
+	}
+	if result.RowsAffected == 0 {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusConflict, "Verification was already reviewed")
+	}
+
+	raiseEvent(tx, events.New(events.KYCReviewed, user.ID, map[string]interface{}{
+		"status": status,
+		"reason": reason,
+	}))
+
+	if err := h.commitTx(tx); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save review")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Verification " + status,
+	})
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"car-rental/internal/models"
+	"car-rental/pkg/database"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCheckRentalKYC(t *testing.T) {
+	rentalEnd := time.Date(2026, 11, 3, 0, 0, 0, 0, time.UTC)
+	valid := rentalEnd.AddDate(1, 0, 0)
+	expiring := rentalEnd.AddDate(0, 0, -1)
+
+	tests := []struct {
+		name   string
+		user   models.User
+		status int // 0 when the rental is allowed
+	}{
+		{"approved", models.User{KYCStatus: "approved", LicenseExpiry: &valid}, 0},
+		{"licence valid on the last day", models.User{KYCStatus: "approved", LicenseExpiry: &rentalEnd}, 0},
+		{"pending", models.User{KYCStatus: "pending", LicenseExpiry: &valid}, http.StatusForbidden},
+		{"rejected", models.User{KYCStatus: "rejected", LicenseExpiry: &valid}, http.StatusForbidden},
+		{"no licence expiry", models.User{KYCStatus: "approved"}, http.StatusForbidden},
+		{"licence expires during the rental", models.User{KYCStatus: "approved", LicenseExpiry: &expiring}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		err := checkRentalKYC(tt.user, rentalEnd)
+		status := 0
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			status = httpErr.Code
+		} else if err != nil {
+			t.Fatalf("%s: unexpected error %v", tt.name, err)
+		}
+		if status != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, status, tt.status)
+		}
+	}
+}
+
+func TestReviewKYCDecidesOnce(t *testing.T) {
+	h := useTestDB(t)
+	user := createTestUser(t)
+	if err := database.DB.Model(&user).Update("kyc_status", "pending").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Two admins act on the same submission at the same time
+	const reviewers = 5
+	var wg sync.WaitGroup
+	statuses := make(chan int, reviewers)
+	for i := 0; i < reviewers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses <- userRequest(t, h.ApproveKYC, 0, http.MethodPost, user.ID, "").Code
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	approved := 0
+	for status := range statuses {
+		switch status {
+		case http.StatusOK:
+			approved++
+		case http.StatusConflict, http.StatusBadRequest:
+		default:
+			t.Errorf("unexpected status %d", status)
+		}
+	}
+	if approved != 1 {
+		t.Fatalf("%d reviews succeeded, want exactly 1", approved)
+	}
+
+	var notifications int64
+	database.DB.Model(&models.UserNotification{}).Where("user_id = ? AND type = ?", user.ID, "kyc").Count(&notifications)
+	if notifications != 1 {
+		t.Errorf("%d review notifications, want 1", notifications)
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"car-rental/internal/models"
+	"car-rental/internal/services"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"time"
+)
+
+// tooManyAttempts builds a 429 response with Retry-After
+func tooManyAttempts(c echo.Context, wait time.Duration) error {
+	c.Response().Header().Set("Retry-After", fmt.Sprintf("%d", int(wait.Seconds())+1))
+	return echo.NewHTTPError(http.StatusTooManyRequests, "Too many login attempts, please try again later")
+}
+
+// recordLoginAttempt stores a login attempt for the activity log
+func (h *Handler) recordLoginAttempt(c echo.Context, email string, userID *uint, success bool) {
+	attempt := models.LoginAttempt{
+		UserID:    userID,
+		Email:     email,
+		IPAddress: c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+		Success:   success,
+	}
+	if err := h.DB.Create(&attempt).Error; err != nil {
+		fmt.Printf("Error recording login attempt: %v\n", err)
+	}
+}
+
+// registerLoginFailure counts a failed password and locks the account past the threshold.
+// The count is incremented in the database so parallel attempts are all counted,
+// and starts over once a previous lockout has run out.
+func (h *Handler) registerLoginFailure(user *models.User) {
+	now := time.Now()
+	tx := h.DB.Begin()
+
+	var failures int
+	if err := tx.Raw(`UPDATE users SET
+			failed_login_count = CASE WHEN locked_until <= ? THEN 1 ELSE failed_login_count + 1 END,
+			unlock_token_hash = CASE WHEN locked_until <= ? THEN NULL ELSE unlock_token_hash END,
+			locked_until = CASE WHEN locked_until <= ? THEN NULL ELSE locked_until END,
+			updated_at = ?
+		WHERE id = ?
+		RETURNING failed_login_count`, now, now, now, now, user.ID).Scan(&failures).Error; err != nil {
+		rollbackTx(tx)
+		fmt.Printf("Error updating failed login count: %v\n", err)
+		return
+	}
+
+	if failures < settings.Login.MaxFailures {
+		if err := h.commitTx(tx); err != nil {
+			fmt.Printf("Error updating failed login count: %v\n", err)
+		}
+		return
+	}
+
+	raw, err := services.RandomToken(32)
+	if err != nil {
+		rollbackTx(tx)
+		fmt.Printf("Error generating unlock token: %v\n", err)
+		return
+	}
+
+	// Only the attempt that actually locks the account sends the email
+	result := tx.Model(&models.User{}).
+		Where("id = ? AND (locked_until IS NULL OR locked_until <= ?)", user.ID, now).
+		Updates(map[string]interface{}{
+			"locked_until":      now.Add(settings.Login.LockoutDuration),
+			"unlock_token_hash": services.HashToken(raw),
+		})
+	if result.Error != nil {
+		rollbackTx(tx)
+		fmt.Printf("Error locking account: %v\n", result.Error)
+		return
+	}
+
+	if result.RowsAffected > 0 {
+		if err := h.notifyUser(tx, *user, "security", "account_locked", map[string]interface{}{
+			"Failures": failures,
+			"URL":      fmt.Sprintf("%s/api/v1/unlock-account?token=%s", settings.App.URL, raw),
+		}); err != nil {
+			rollbackTx(tx)
+			fmt.Printf("Error queueing lockout notification: %v\n", err)
+			return
+		}
+	}
+
+	if err := h.commitTx(tx); err != nil {
+		fmt.Printf("Error updating failed login count: %v\n", err)
+	}
+}
+
+// UnlockAccount handler
+func (h *Handler) UnlockAccount(c echo.Context) error {
+	token := c.QueryParam("token")
+	if token == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid unlock link")
+	}
+
+	var user models.User
+	if err := h.DB.Where("unlock_token_hash = ?", services.HashToken(token)).First(&user).Error; err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid unlock link")
+	}
+
+	if err := h.DB.Model(&user).Updates(map[string]interface{}{
+		"failed_login_count": 0,
+		"locked_until":       nil,
+		"unlock_token_hash":  nil,
+	}).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to unlock account")
+	}
+
+	if err := h.Limiter.ResetAccount(user.Email); err != nil {
+		fmt.Printf("Error resetting login limiter: %v\n", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Account unlocked",
+	})
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"car-rental/internal/models"
+	"car-rental/internal/services"
+	"car-rental/pkg/database"
+	"encoding/json"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// useLoginSettings lowers the lockout threshold and disables the progressive delays for a test
+func useLoginSettings(t *testing.T, h *Handler, maxFailures int) {
+	t.Helper()
+
+	previous := settings.Login
+	t.Cleanup(func() { settings.Login = previous })
+
+	settings.Login.MaxFailures = maxFailures
+	settings.Login.LockoutDuration = 15 * time.Minute
+
+	limiter := services.NewLoginLimiter(services.NewMemoryRateLimitStore())
+	limiter.FreeAttempts = 1000
+	limiter.MaxPerIP = 1000
+	h.Limiter = limiter
+}
+
+// createLoginUser stores a user whose password is "correct-password"
+func createLoginUser(t *testing.T) models.User {
+	t.Helper()
+
+	user := createTestUser(t)
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct-password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.DB.Model(&user).Update("password", string(hash)).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func login(t *testing.T, h *Handler, email, password string) int {
+	t.Helper()
+
+	body, _ := json.Marshal(LoginRequest{Email: email, Password: password})
+	return request(t, h.Login, http.MethodPost, string(body)).Code
+}
+
+func reloadUser(t *testing.T, id uint) models.User {
+	t.Helper()
+
+	var user models.User
+	if err := database.DB.First(&user, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestLoginLockout(t *testing.T) {
+	h := useTestDB(t)
+	useLoginSettings(t, h, 3)
+	user := createLoginUser(t)
+
+	for i := 0; i < 3; i++ {
+		if status := login(t, h, user.Email, "wrong-password"); status != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: status = %d, want 401", i+1, status)
+		}
+	}
+
+	// Locked, even with the right password
+	if status := login(t, h, user.Email, "correct-password"); status != http.StatusLocked {
+		t.Fatalf("status = %d, want 423 while locked", status)
+	}
+
+	locked := reloadUser(t, user.ID)
+	if locked.FailedLoginCount != 3 || locked.LockedUntil == nil || locked.UnlockTokenHash == nil {
+		t.Fatalf("count %d, locked until %v, want a lockout after 3 failures", locked.FailedLoginCount, locked.LockedUntil)
+	}
+
+	var emails int64
+	database.DB.Model(&models.UserNotification{}).Where("user_id = ? AND type = ?", user.ID, "security").Count(&emails)
+	if emails != 1 {
+		t.Errorf("%d lockout notifications, want 1", emails)
+	}
+}
+
+func TestLoginFailuresStartOverAfterLockout(t *testing.T) {
+	h := useTestDB(t)
+	useLoginSettings(t, h, 3)
+	user := createLoginUser(t)
+
+	expired := time.Now().Add(-time.Minute)
+	database.DB.Model(&user).Updates(map[string]interface{}{
+		"failed_login_count": 3,
+		"locked_until":       expired,
+		"unlock_token_hash":  "stale",
+	})
+
+	if status := login(t, h, user.Email, "wrong-password"); status != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401 once the lockout ran out", status)
+	}
+
+	after := reloadUser(t, user.ID)
+	if after.FailedLoginCount != 1 || after.LockedUntil != nil || after.UnlockTokenHash != nil {
+		t.Fatalf("count %d, locked until %v, want a fresh window", after.FailedLoginCount, after.LockedUntil)
+	}
+}
+
+func TestRegisterLoginFailureCountsParallelAttempts(t *testing.T) {
+	h := useTestDB(t)
+	useLoginSettings(t, h, 100)
+	user := createLoginUser(t)
+
+	const attempts = 10
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stale := user
+			h.registerLoginFailure(&stale)
+		}()
+	}
+	wg.Wait()
+
+	if count := reloadUser(t, user.ID).FailedLoginCount; count != attempts {
+		t.Fatalf("failed_login_count = %d, want %d", count, attempts)
+	}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"car-rental/internal/config"
+	"car-rental/internal/events"
+	"car-rental/internal/migrations"
+	"car-rental/internal/models"
+	"car-rental/internal/repository"
+	"car-rental/internal/services"
+	"car-rental/pkg/database"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// useTestDB points database.DB at TEST_DATABASE_URL with every migration applied
+// and returns a Handler on it with the subscribers registered on a private bus.
+// Tests that need PostgreSQL are skipped when it is not set.
+func useTestDB(t *testing.T) *Handler {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("connect to test database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrations.Up(sqlDB); err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = previous
+		sqlDB.Close()
+	})
+
+	services.ConfigureJWT(config.JWT{
+		Secret:          "test-secret",
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: 24 * time.Hour,
+	})
+
+	h := NewHandler(db, repository.NewGorm(db), &testMailer{}, nil)
+	h.Events = events.NewBus()
+	h.RegisterSubscribers(h.Events)
+	return h
+}
+
+// testMailer records the emails it is asked to send
+type testMailer struct {
+	mu   sync.Mutex
+	sent []string // recipients
+}
+
+func (m *testMailer) SendEmail(to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, to)
+	return nil
+}
+
+func (m *testMailer) SendMultipart(to, subject, text, html string) error {
+	return m.SendEmail(to, subject, text)
+}
+
+var testUserSeq int64
+
+// createTestUser stores a verified customer with a unique email
+func createTestUser(t *testing.T) models.User {
+	t.Helper()
+
+	now := time.Now()
+	user := models.User{
+		Email:      fmt.Sprintf("user%d-%d@example.com", now.UnixNano(), atomic.AddInt64(&testUserSeq, 1)),
+		Password:   "not-a-bcrypt-hash",
+		VerifiedAt: &now,
+	}
+	if err := database.DB.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+// request runs handler against a JSON request and returns the recorded response
+func request(t *testing.T, handler echo.HandlerFunc, method, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	e := echo.New()
+	req := httptest.NewRequest(method, "/", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+
+	if err := handler(e.NewContext(req, rec)); err != nil {
+		e.HTTPErrorHandler(err, e.NewContext(req, rec))
+	}
+	return rec
+}
+
+// expectStatus fails the test when the response has another status
+func expectStatus(t *testing.T, rec *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if rec.Code != status {
+		t.Fatalf("status = %d (%s), want %d %s", rec.Code, strings.TrimSpace(rec.Body.String()), status, http.StatusText(status))
+	}
+}
@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"car-rental/internal/emails"
+	"car-rental/internal/events"
+	"car-rental/internal/models"
+	"car-rental/internal/outbox"
+	"car-rental/internal/repository"
+	"car-rental/internal/services"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+	"strings"
+)
+
+// notificationTypes lists the types users can set channel preferences for
+var notificationTypes = []string{"registration", "login", "security", "topup", "rental", "return", "reminder", "waitlist", "kyc"}
+
+// mandatoryEmailTypes always go out by email, they carry verification and security links
+var mandatoryEmailTypes = map[string]bool{
+	"registration": true,
+	"security":     true,
+}
+
+type UpdateNotificationPreferencesRequest struct {
+	WebhookURL  *string             `json:"webhook_url"`
+	Preferences map[string][]string `json:"preferences"`
+}
+
+// notificationChannels returns the user's channels for a type, email by default
+func (h *Handler) notificationChannels(tx *gorm.DB, userID uint, notifType string) ([]string, error) {
+	var preference models.NotificationPreference
+	err := tx.Where("user_id = ? AND type = ?", userID, notifType).First(&preference).Error
+	if err == gorm.ErrRecordNotFound {
+		return []string{services.ChannelEmail}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Channels chosen before they became unavailable are skipped
+	channels := []string{}
+	for _, channel := range preference.ChannelList() {
+		if h.channelAvailable(channel) {
+			channels = append(channels, channel)
+		}
+	}
+	if mandatoryEmailTypes[notifType] && !containsString(channels, services.ChannelEmail) {
+		channels = append(channels, services.ChannelEmail)
+	}
+	return channels, nil
+}
+
+// notifyUser renders an email template in the user's language, records the
+// notification and queues it on each of the user's channels in the caller's transaction
+func (h *Handler) notifyUser(tx *gorm.DB, user models.User, notifType, templateName string, data map[string]interface{}) error {
+	msg, err := emails.Render(templateName, user.Language, data)
+	if err != nil {
+		return err
+	}
+
+	channels, err := h.notificationChannels(tx, user.ID, notifType)
+	if err != nil {
+		return err
+	}
+
+	emailStatus := "skipped"
+	if containsString(channels, services.ChannelEmail) {
+		emailStatus = "pending"
+	}
+
+	notification := models.UserNotification{
+		UserID:      user.ID,
+		Type:        notifType,
+		EmailStatus: emailStatus,
+		Subject:     msg.Subject,
+		Message:     msg.Summary,
+	}
+	if err := tx.Create(&notification).Error; err != nil {
+		return err
+	}
+
+	raiseEvent(tx, events.New(events.NotificationCreated, user.ID, map[string]interface{}{
+		"id":      notification.ID,
+		"type":    notification.Type,
+		"subject": notification.Subject,
+		"message": notification.Message,
+	}))
+
+	// Fan out to every channel the user can be reached on
+	for _, channel := range channels {
+		n := services.Notification{
+			Type:    notifType,
+			Subject: msg.Subject,
+			Text:    msg.Text,
+		}
+		switch channel {
+		case services.ChannelEmail:
+			n.Recipient = user.Email
+			n.HTML = msg.HTML
+		case services.ChannelSMS, services.ChannelWhatsApp:
+			n.Recipient = user.Phone
+		case services.ChannelWebhook:
+			// Third-party endpoints never receive secret links
+			n.Recipient = user.WebhookURL
+			n.Text = msg.Summary
+		}
+		if n.Recipient == "" {
+			continue
+		}
+
+		if err := outbox.Enqueue(tx, &notification.ID, channel, n); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// GetNotificationPreferences handler
+func (h *Handler) GetNotificationPreferences(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+
+	var user models.User
+	if err := h.DB.First(&user, userID).Error; err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+
+	preferences := map[string][]string{}
+	for _, notifType := range notificationTypes {
+		channels, err := h.notificationChannels(h.DB, userID, notifType)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch preferences")
+		}
+		preferences[notifType] = channels
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"channels":    h.availableChannels(),
+		"phone":       user.Phone,
+		"webhook_url": user.WebhookURL,
+		"preferences": preferences,
+	})
+}
+
+// UpdateNotificationPreferences handler
+func (h *Handler) UpdateNotificationPreferences(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+
+	var req UpdateNotificationPreferencesRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	var user models.User
+	if err := h.DB.First(&user, userID).Error; err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+
+	webhookURL := user.WebhookURL
+	if req.WebhookURL != nil {
+		webhookURL = strings.TrimSpace(*req.WebhookURL)
+		if webhookURL != "" {
+			// Notifications are posted from inside our network, internal addresses are off limits
+			if err := services.ValidatePublicURL(c.Request().Context(), webhookURL); err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "Webhook URL must be a public http or https URL")
+			}
+		}
+	}
+
+	for notifType, channels := range req.Preferences {
+		if !containsString(notificationTypes, notifType) {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Unknown notification type %q", notifType))
+		}
+		for _, channel := range channels {
+			if !services.ValidChannel(channel) {
+				return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Unknown channel %q", channel))
+			}
+			if !h.channelAvailable(channel) {
+				return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Channel %q is not available", channel))
+			}
+			if (channel == services.ChannelSMS || channel == services.ChannelWhatsApp) && user.Phone == "" {
+				return echo.NewHTTPError(http.StatusBadRequest, "Add a phone number to your profile before choosing "+channel)
+			}
+			if channel == services.ChannelWebhook && webhookURL == "" {
+				return echo.NewHTTPError(http.StatusBadRequest, "Set a webhook URL before choosing webhook")
+			}
+		}
+		if mandatoryEmailTypes[notifType] && !containsString(channels, services.ChannelEmail) {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Email cannot be turned off for %s notifications", notifType))
+		}
+	}
+
+	tx := h.DB.Begin()
+
+	if req.WebhookURL != nil {
+		if err := tx.Model(&user).Update("webhook_url", webhookURL).Error; err != nil {
+			rollbackTx(tx)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update preferences")
+		}
+	}
+
+	for notifType, channels := range req.Preferences {
+		unique := []string{}
+		for _, channel := range channels {
+			if !containsString(unique, channel) {
+				unique = append(unique, channel)
+			}
+		}
+
+		var preference models.NotificationPreference
+		if err := tx.Where(models.NotificationPreference{UserID: userID, Type: notifType}).
+			Assign(models.NotificationPreference{Channels: strings.Join(unique, ",")}).
+			FirstOrCreate(&preference).Error; err != nil {
+			rollbackTx(tx)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update preferences")
+		}
+	}
+
+	if err := h.commitTx(tx); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update preferences")
+	}
+
+	return h.GetNotificationPreferences(c)
+}
+
+// GetNotifications handler
+func (h *Handler) GetNotifications(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+
+	notifications, err := h.Notifications.ListByUser(userID, c.QueryParam("unread") == "true", 100)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch notifications")
+	}
+
+	unread, err := h.Notifications.CountUnread(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch notifications")
+	}
+
+	formattedNotifications := []map[string]interface{}{}
+	for _, notification := range notifications {
+		formattedNotifications = append(formattedNotifications, map[string]interface{}{
+			"id":           notification.ID,
+			"type":         notification.Type,
+			"subject":      notification.Subject,
+			"message":      notification.Message,
+			"email_status": notification.EmailStatus,
+			"read":         notification.ReadAt != nil,
+			"created_at":   notification.CreatedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data":         formattedNotifications,
+		"unread_count": unread,
+	})
+}
+
+// MarkNotificationRead handler
+func (h *Handler) MarkNotificationRead(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+	notificationID, ok := paramID(c, "id")
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "Notification not found")
+	}
+
+	if err := h.Notifications.MarkRead(notificationID, userID); err == repository.ErrNotFound {
+		return echo.NewHTTPError(http.StatusNotFound, "Notification not found")
+	} else if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update notification")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Notification marked as read",
+	})
+}
+
+// MarkAllNotificationsRead handler
+func (h *Handler) MarkAllNotificationsRead(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+
+	if err := h.Notifications.MarkAllRead(userID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update notifications")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "All notifications marked as read",
+	})
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"car-rental/internal/models"
+	"encoding/json"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type notificationList struct {
+	Data []struct {
+		ID   uint `json:"id"`
+		Read bool `json:"read"`
+	} `json:"data"`
+	UnreadCount int64 `json:"unread_count"`
+}
+
+// listNotifications runs GetNotifications as the user, with ?unread=true when unreadOnly is set
+func listNotifications(t *testing.T, h *Handler, userID uint, unreadOnly bool) notificationList {
+	t.Helper()
+
+	target := "/"
+	if unreadOnly {
+		target = "/?unread=true"
+	}
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, target, nil), rec)
+	c.Set("userID", userID)
+	if err := h.GetNotifications(c); err != nil {
+		e.HTTPErrorHandler(err, c)
+	}
+	expectStatus(t, rec, http.StatusOK)
+
+	var list notificationList
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	return list
+}
+
+func TestNotificationHandlers(t *testing.T) {
+	h, memory := newMemoryHandler()
+	user := memory.AddUser(models.User{Email: "user@example.com"})
+	other := memory.AddUser(models.User{Email: "other@example.com"})
+	first := memory.AddNotification(models.UserNotification{UserID: user.ID, Type: "rental", Subject: "Rental created"})
+	memory.AddNotification(models.UserNotification{UserID: user.ID, Type: "topup", Subject: "Top up received"})
+	foreign := memory.AddNotification(models.UserNotification{UserID: other.ID, Type: "rental", Subject: "Rental created"})
+
+	list := listNotifications(t, h, user.ID, false)
+	if len(list.Data) != 2 || list.UnreadCount != 2 {
+		t.Fatalf("listed %d notifications with %d unread, want 2 and 2", len(list.Data), list.UnreadCount)
+	}
+	for _, n := range list.Data {
+		if n.ID == foreign.ID {
+			t.Fatal("listed another user's notification")
+		}
+	}
+
+	// Another user's notification looks like a missing one
+	expectStatus(t, userRequest(t, h.MarkNotificationRead, user.ID, http.MethodPut, foreign.ID, ""), http.StatusNotFound)
+	expectStatus(t, userRequest(t, h.MarkNotificationRead, user.ID, http.MethodPut, 999, ""), http.StatusNotFound)
+	if list := listNotifications(t, h, other.ID, true); len(list.Data) != 1 {
+		t.Fatalf("other user has %d unread notifications, want 1", len(list.Data))
+	}
+
+	expectStatus(t, userRequest(t, h.MarkNotificationRead, user.ID, http.MethodPut, first.ID, ""), http.StatusOK)
+	expectStatus(t, userRequest(t, h.MarkNotificationRead, user.ID, http.MethodPut, first.ID, ""), http.StatusOK)
+	list = listNotifications(t, h, user.ID, true)
+	if len(list.Data) != 1 || list.Data[0].ID == first.ID || list.UnreadCount != 1 {
+		t.Fatalf("unread after marking one: %+v, want only the second notification", list)
+	}
+
+	expectStatus(t, userRequest(t, h.MarkAllNotificationsRead, user.ID, http.MethodPut, 0, ""), http.StatusOK)
+	list = listNotifications(t, h, user.ID, false)
+	if len(list.Data) != 2 || list.UnreadCount != 0 {
+		t.Fatalf("after marking all read: %+v, want 2 read notifications", list)
+	}
+	for _, n := range list.Data {
+		if !n.Read {
+			t.Fatalf("notification %d is still unread", n.ID)
+		}
+	}
+	if list := listNotifications(t, h, other.ID, true); len(list.Data) != 1 {
+		t.Fatal("marking all read touched another user's notifications")
+	}
+}
@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"car-rental/internal/models"
+	"car-rental/internal/services"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	oidcOnce    sync.Once
+	oidcService *services.OIDCService
+)
+
+// getOIDCService creates the OIDC client once the settings are loaded
+func (h *Handler) getOIDCService() *services.OIDCService {
+	oidcOnce.Do(func() {
+		oidcService = services.NewOIDCService(settings.OIDC)
+	})
+	return oidcService
+}
+
+// OIDCLogin handler
+func (h *Handler) OIDCLogin(c echo.Context) error {
+	oidc := h.getOIDCService()
+	if !oidc.Enabled() {
+		return echo.NewHTTPError(http.StatusNotFound, "OIDC login is not configured")
+	}
+
+	state, err := services.RandomToken(16)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to start login")
+	}
+	nonce, err := services.RandomToken(16)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to start login")
+	}
+	verifier, err := services.RandomToken(32)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to start login")
+	}
+
+	// Keep the PKCE verifier server side until the callback
+	if err := h.DB.Create(&models.OIDCState{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(10 * time.Minute),
+	}).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to start login")
+	}
+
+	authURL, err := oidc.AuthURL(state, nonce, verifier)
+	if err != nil {
+		fmt.Printf("Error building OIDC auth URL: %v\n", err)
+		return echo.NewHTTPError(http.StatusBadGateway, "Identity provider unavailable")
+	}
+
+	return c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback handler
+func (h *Handler) OIDCCallback(c echo.Context) error {
+	oidc := h.getOIDCService()
+	if !oidc.Enabled() {
+		return echo.NewHTTPError(http.StatusNotFound, "OIDC login is not configured")
+	}
+
+	if errCode := c.QueryParam("error"); errCode != "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Login was not approved: "+errCode)
+	}
+
+	// State is single use
+	var state models.OIDCState
+	if err := h.DB.Where("state = ?", c.QueryParam("state")).First(&state).Error; err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid login state")
+	}
+	h.DB.Delete(&state)
+
+	if time.Now().After(state.ExpiresAt) {
+		return echo.NewHTTPError(http.StatusBadRequest, "Login expired, please try again")
+	}
+
+	claims, err := oidc.Exchange(c.QueryParam("code"), state.CodeVerifier, state.Nonce)
+	if err != nil {
+		fmt.Printf("Error exchanging OIDC code: %v\n", err)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Failed to verify identity")
+	}
+
+	user, err := h.linkOIDCIdentity(claims)
+	if err != nil {
+		return err
+	}
+
+	// Two-factor authentication still applies
+	if user.TOTPEnabledAt != nil {
+		challenge, err := services.GenerateTwoFactorChallenge(user.ID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate challenge")
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"two_factor_required": true,
+			"challenge_token":     challenge,
+		})
+	}
+
+	h.recordLoginAttempt(c, user.Email, &user.ID, true)
+
+	return h.completeLogin(c, *user)
+}
+
+// linkOIDCIdentity finds the user for an identity or creates a new account.
+// An existing account with the same email is never linked here, its owner
+// must confirm through the link emailed by requestOIDCLink.
+func (h *Handler) linkOIDCIdentity(claims *services.OIDCClaims) (*models.User, error) {
+	var identity models.UserIdentity
+	if err := h.DB.Preload("User").
+		Where("issuer = ? AND subject = ?", claims.Issuer, claims.Subject).
+		First(&identity).Error; err == nil {
+		return &identity.User, nil
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, echo.NewHTTPError(http.StatusForbidden, "Identity provider did not return a verified email")
+	}
+	claims.Email = services.NormalizeEmail(claims.Email)
+
+	var existing models.User
+	if err := h.DB.Where("LOWER(email) = ?", claims.Email).First(&existing).Error; err == nil {
+		return nil, h.requestOIDCLink(existing, claims)
+	}
+
+	// New account, the password is random since login goes through the provider
+	randomPassword, err := services.RandomToken(32)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to create account")
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(randomPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to create account")
+	}
+
+	tx := h.DB.Begin()
+
+	now := time.Now()
+	user := models.User{
+		Email:      claims.Email,
+		Password:   string(hashedPassword),
+		VerifiedAt: &now,
+	}
+	if err := tx.Create(&user).Error; err != nil {
+		rollbackTx(tx)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to create account")
+	}
+
+	identity = models.UserIdentity{
+		UserID:  user.ID,
+		Issuer:  claims.Issuer,
+		Subject: claims.Subject,
+		Email:   claims.Email,
+	}
+	if err := tx.Create(&identity).Error; err != nil {
+		rollbackTx(tx)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to create account")
+	}
+
+	if err := h.commitTx(tx); err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to create account")
+	}
+
+	return &user, nil
+}
+
+// requestOIDCLink emails the owner of an existing account a link to confirm the new identity.
+// It always returns an error, since no one is logged in until the link is confirmed.
+func (h *Handler) requestOIDCLink(user models.User, claims *services.OIDCClaims) error {
+	token, err := services.GenerateOIDCLinkToken(user.ID, claims)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to link account")
+	}
+
+	tx := h.DB.Begin()
+	if err := h.notifyUser(tx, user, "security", "oidc_link", map[string]interface{}{
+		"Issuer": claims.Issuer,
+		"URL":    fmt.Sprintf("%s/api/v1/oidc/link/confirm?token=%s", settings.App.URL, token),
+	}); err != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to link account")
+	}
+	if err := h.commitTx(tx); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to link account")
+	}
+
+	return echo.NewHTTPError(http.StatusConflict, "An account with this email already exists, check your email to confirm linking it")
+}
+
+// ConfirmOIDCLink handler
+func (h *Handler) ConfirmOIDCLink(c echo.Context) error {
+	userID, claims, err := services.ParseOIDCLinkToken(c.QueryParam("token"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired link")
+	}
+
+	var user models.User
+	if err := h.DB.First(&user, userID).Error; err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired link")
+	}
+	// The link is void once the account changes its email
+	if services.NormalizeEmail(user.Email) != claims.Email {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired link")
+	}
+
+	var identity models.UserIdentity
+	if err := h.DB.Where("issuer = ? AND subject = ?", claims.Issuer, claims.Subject).First(&identity).Error; err == nil {
+		if identity.UserID != user.ID {
+			return echo.NewHTTPError(http.StatusConflict, "This identity is linked to another account")
+		}
+		return c.JSON(http.StatusOK, map[string]string{
+			"message": "Account already linked",
+		})
+	}
+
+	tx := h.DB.Begin()
+
+	identity = models.UserIdentity{
+		UserID:  user.ID,
+		Issuer:  claims.Issuer,
+		Subject: claims.Subject,
+		Email:   claims.Email,
+	}
+	if err := tx.Create(&identity).Error; err != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to link account")
+	}
+
+	// Clicking the emailed link proves the address as well
+	if user.VerifiedAt == nil {
+		if err := tx.Model(&user).Update("verified_at", time.Now()).Error; err != nil {
+			rollbackTx(tx)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to link account")
+		}
+	}
+
+	if err := h.commitTx(tx); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to link account")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Account linked, you can now sign in with your identity provider",
+	})
+}
+
+// PurgeOIDCStates removes abandoned login attempts
+func (h *Handler) PurgeOIDCStates() {
+	h.DB.Where("expires_at < ?", time.Now()).Delete(&models.OIDCState{})
+}
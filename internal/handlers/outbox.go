@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"car-rental/internal/models"
+	"car-rental/internal/outbox"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+)
+
+// GetOutboxMessages handler
+func (h *Handler) GetOutboxMessages(c echo.Context) error {
+	status := c.QueryParam("status")
+	if status == "" {
+		status = "dead"
+	}
+
+	var messages []models.OutboxMessage
+	if err := h.DB.Where("status = ?", status).
+		Order("updated_at DESC").
+		Limit(100).
+		Find(&messages).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch outbox")
+	}
+
+	// Counts per status for a quick overview
+	var counts []struct {
+		Status string `json:"status"`
+		Count  int64  `json:"count"`
+	}
+	h.DB.Model(&models.OutboxMessage{}).
+		Select("status, COUNT(*) AS count").
+		Group("status").
+		Scan(&counts)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data":   messages,
+		"counts": counts,
+	})
+}
+
+// RequeueOutboxMessage handler
+func (h *Handler) RequeueOutboxMessage(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid message id")
+	}
+
+	if err := outbox.Requeue(h.DB, uint(id)); err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Dead message not found")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Message requeued",
+	})
+}
@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"car-rental/internal/events"
+	"car-rental/internal/models"
+	"car-rental/internal/services"
+	"car-rental/internal/webhooks"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// webhookEventTypes are the events integrators can subscribe to
+var webhookEventTypes = []string{
+	events.RentalCreated,
+	events.PaymentUpdated,
+	events.PaymentPaid,
+	events.RentalActivated,
+	events.CarReturned,
+	events.RentalCancelled,
+}
+
+type WebhookEndpointRequest struct {
+	URL         string   `json:"url" validate:"required,url"`
+	Description string   `json:"description"`
+	EventTypes  []string `json:"event_types" validate:"required"`
+	Active      *bool    `json:"active"`
+}
+
+// formatWebhookEndpoint hides the secret and shows event types as a list
+func formatWebhookEndpoint(endpoint models.WebhookEndpoint) map[string]interface{} {
+	return map[string]interface{}{
+		"id":          endpoint.ID,
+		"url":         endpoint.URL,
+		"description": endpoint.Description,
+		"event_types": endpoint.EventTypeList(),
+		"active":      endpoint.Active,
+		"created_at":  endpoint.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
+
+// validateWebhookEndpoint checks the URL and event types of a request
+func validateWebhookEndpoint(ctx context.Context, req WebhookEndpointRequest) error {
+	// Deliveries are posted from inside our network, internal addresses are off limits
+	if err := services.ValidatePublicURL(ctx, req.URL); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "URL must be a public http or https URL")
+	}
+
+	if len(req.EventTypes) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "At least one event type is required")
+	}
+	for _, eventType := range req.EventTypes {
+		if eventType != "*" && !containsString(webhookEventTypes, eventType) {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Unknown event type %q", eventType))
+		}
+	}
+	return nil
+}
+
+// queueWebhooks stores a delivery for every endpoint subscribed to the event
+func queueWebhooks(tx *gorm.DB, e events.Event) error {
+	var endpoints []models.WebhookEndpoint
+	if err := tx.Where("active = ?", true).Find(&endpoints).Error; err != nil {
+		return fmt.Errorf("fetch webhook endpoints: %w", err)
+	}
+
+	var subscribed []models.WebhookEndpoint
+	for _, endpoint := range endpoints {
+		if endpoint.Subscribes(e.Type) {
+			subscribed = append(subscribed, endpoint)
+		}
+	}
+	if len(subscribed) == 0 {
+		return nil
+	}
+
+	token, err := services.RandomToken(12)
+	if err != nil {
+		return fmt.Errorf("generate webhook event id: %w", err)
+	}
+	eventID := "evt_" + token
+
+	body := map[string]interface{}{
+		"id":          eventID,
+		"type":        e.Type,
+		"occurred_at": e.OccurredAt.UTC(),
+		"data":        e.Data,
+	}
+
+	// Integrators get the current state of the rental with every event
+	var rental models.RentalHistory
+	if err := tx.Preload("Car").First(&rental, e.Uint("rental_id")).Error; err == nil {
+		body["rental"] = map[string]interface{}{
+			"id":           rental.ID,
+			"user_id":      rental.UserID,
+			"car_id":       rental.CarID,
+			"car_name":     rental.Car.Name,
+			"rental_start": rental.RentalStart.Format("2006-01-02"),
+			"rental_end":   rental.RentalEnd.Format("2006-01-02"),
+			"total_cost":   rental.TotalCost,
+			"status":       rental.Status,
+			"partner_id":   rental.PartnerID,
+		}
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("encode webhook payload: %w", err)
+	}
+
+	for _, endpoint := range subscribed {
+		if err := webhooks.Enqueue(tx, endpoint.ID, eventID, e.Type, payload); err != nil {
+			return fmt.Errorf("queue webhook for endpoint %d: %w", endpoint.ID, err)
+		}
+	}
+	return nil
+}
+
+// GetWebhookEndpoints handler
+func (h *Handler) GetWebhookEndpoints(c echo.Context) error {
+	var endpoints []models.WebhookEndpoint
+	if err := h.DB.Order("id ASC").Find(&endpoints).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch webhook endpoints")
+	}
+
+	formattedEndpoints := []map[string]interface{}{}
+	for _, endpoint := range endpoints {
+		formattedEndpoints = append(formattedEndpoints, formatWebhookEndpoint(endpoint))
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data":        formattedEndpoints,
+		"event_types": webhookEventTypes,
+	})
+}
+
+// CreateWebhookEndpoint handler, the signing secret is only shown here
+func (h *Handler) CreateWebhookEndpoint(c echo.Context) error {
+	var req WebhookEndpointRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := validateWebhookEndpoint(c.Request().Context(), req); err != nil {
+		return err
+	}
+
+	secret, err := services.RandomToken(32)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate secret")
+	}
+
+	endpoint := models.WebhookEndpoint{
+		URL:         req.URL,
+		Description: req.Description,
+		EventTypes:  strings.Join(req.EventTypes, ","),
+		Secret:      "whsec_" + secret,
+		Active:      req.Active == nil || *req.Active,
+	}
+	if err := h.DB.Create(&endpoint).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create webhook endpoint")
+	}
+
+	response := formatWebhookEndpoint(endpoint)
+	response["secret"] = endpoint.Secret
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"message": "Store the secret now, it will not be shown again",
+		"data":    response,
+	})
+}
+
+// UpdateWebhookEndpoint handler
+func (h *Handler) UpdateWebhookEndpoint(c echo.Context) error {
+	var endpoint models.WebhookEndpoint
+	if err := h.DB.First(&endpoint, c.Param("id")).Error; err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Webhook endpoint not found")
+	}
+
+	var req WebhookEndpointRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := validateWebhookEndpoint(c.Request().Context(), req); err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{
+		"url":         req.URL,
+		"description": req.Description,
+		"event_types": strings.Join(req.EventTypes, ","),
+	}
+	if req.Active != nil {
+		updates["active"] = *req.Active
+	}
+
+	if err := h.DB.Model(&endpoint).Updates(updates).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update webhook endpoint")
+	}
+	h.DB.First(&endpoint, endpoint.ID)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": formatWebhookEndpoint(endpoint),
+	})
+}
+
+// DeleteWebhookEndpoint handler removes the endpoint and its delivery log
+func (h *Handler) DeleteWebhookEndpoint(c echo.Context) error {
+	var endpoint models.WebhookEndpoint
+	if err := h.DB.First(&endpoint, c.Param("id")).Error; err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Webhook endpoint not found")
+	}
+
+	tx := h.DB.Begin()
+
+	if err := tx.Where("endpoint_id = ?", endpoint.ID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete webhook endpoint")
+	}
+
+	if err := tx.Delete(&endpoint).Error; err != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete webhook endpoint")
+	}
+
+	if err := h.commitTx(tx); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete webhook endpoint")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Webhook endpoint deleted",
+	})
+}
+
+// GetWebhookDeliveries handler lists the delivery log of an endpoint
+func (h *Handler) GetWebhookDeliveries(c echo.Context) error {
+	query := h.DB.Where("endpoint_id = ?", c.Param("id"))
+	if status := c.QueryParam("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var deliveries []models.WebhookDelivery
+	if err := query.Order("created_at DESC").Limit(100).Find(&deliveries).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch webhook deliveries")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": deliveries,
+	})
+}
+
+// RedeliverWebhook handler sends a logged delivery again
+func (h *Handler) RedeliverWebhook(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid delivery id")
+	}
+
+	delivery, err := webhooks.Redeliver(h.DB, uint(id))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Webhook delivery not found")
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"message": "Delivery queued",
+		"data":    delivery,
+	})
+}
@@ -0,0 +1,410 @@
+package handlers
+
+import (
+	"car-rental/internal/models"
+	"car-rental/internal/services"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var apiKeyScopes = map[string]bool{
+	"cars:read":     true,
+	"rentals:read":  true,
+	"rentals:write": true,
+}
+
+type CreatePartnerRequest struct {
+	Name         string `json:"name" validate:"required"`
+	ContactEmail string `json:"contact_email" validate:"required,email"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string   `json:"name" validate:"required"`
+	Scopes    []string `json:"scopes" validate:"required"`
+	ExpiresAt string   `json:"expires_at"`
+}
+
+type PartnerRentalRequest struct {
+	CreateRentalRequest
+	CustomerEmail string `json:"customer_email" validate:"required,email"`
+}
+
+type PartnerCustomerRequest struct {
+	CustomerEmail string `json:"customer_email" validate:"required,email"`
+}
+
+// formatAPIKey hides the key hash and shows scopes as a list
+func formatAPIKey(key models.APIKey) map[string]interface{} {
+	return map[string]interface{}{
+		"id":           key.ID,
+		"partner_id":   key.PartnerID,
+		"name":         key.Name,
+		"prefix":       key.Prefix,
+		"scopes":       key.ScopeList(),
+		"expires_at":   key.ExpiresAt,
+		"last_used_at": key.LastUsedAt,
+		"revoked_at":   key.RevokedAt,
+		"created_at":   key.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
+
+// formatPartnerRental is what a partner sees of a rental, the customer only by reference
+func formatPartnerRental(rental models.RentalHistory) map[string]interface{} {
+	return map[string]interface{}{
+		"id":           rental.ID,
+		"car_id":       rental.CarID,
+		"car_name":     rental.Car.Name,
+		"rental_start": rental.RentalStart.Format("2006-01-02"),
+		"rental_end":   rental.RentalEnd.Format("2006-01-02"),
+		"total_cost":   rental.TotalCost,
+		"status":       rental.Status,
+		"created_at":   rental.CreatedAt.Format("2006-01-02 15:04:05"),
+		"customer": map[string]interface{}{
+			"id": rental.UserID,
+		},
+	}
+}
+
+// CreatePartner handler
+func (h *Handler) CreatePartner(c echo.Context) error {
+	var req CreatePartnerRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if req.Name == "" || req.ContactEmail == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Name and contact email are required")
+	}
+
+	partner := models.Partner{
+		Name:         req.Name,
+		ContactEmail: req.ContactEmail,
+	}
+
+	if err := h.DB.Create(&partner).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create partner")
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"data": partner,
+	})
+}
+
+// GetPartners handler
+func (h *Handler) GetPartners(c echo.Context) error {
+	var partners []models.Partner
+	if err := h.DB.Order("id ASC").Find(&partners).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch partners")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": partners,
+	})
+}
+
+// CreateAPIKey handler
+func (h *Handler) CreateAPIKey(c echo.Context) error {
+	partnerID := c.Param("id")
+
+	var req CreateAPIKeyRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	var partner models.Partner
+	if err := h.DB.First(&partner, partnerID).Error; err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Partner not found")
+	}
+
+	if req.Name == "" || len(req.Scopes) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Name and scopes are required")
+	}
+	for _, scope := range req.Scopes {
+		if !apiKeyScopes[scope] {
+			return echo.NewHTTPError(http.StatusBadRequest, "Unknown scope "+scope)
+		}
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresAt != "" {
+		parsed, err := time.Parse("2006-01-02", req.ExpiresAt)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid expires_at format. Use YYYY-MM-DD")
+		}
+		expiresAt = &parsed
+	}
+
+	// Key is shown once, only its hash is stored
+	prefix, err := services.RandomToken(4)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate API key")
+	}
+	secret, err := services.RandomToken(24)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate API key")
+	}
+	rawKey := "rk_" + prefix + "_" + secret
+
+	key := models.APIKey{
+		PartnerID: partner.ID,
+		Name:      req.Name,
+		Prefix:    "rk_" + prefix,
+		KeyHash:   services.HashToken(rawKey),
+		Scopes:    strings.Join(req.Scopes, ","),
+		ExpiresAt: expiresAt,
+	}
+
+	if err := h.DB.Create(&key).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create API key")
+	}
+
+	response := formatAPIKey(key)
+	response["key"] = rawKey
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"message": "Store this key now, it will not be shown again",
+		"data":    response,
+	})
+}
+
+// GetAPIKeys handler
+func (h *Handler) GetAPIKeys(c echo.Context) error {
+	partnerID := c.Param("id")
+
+	var keys []models.APIKey
+	if err := h.DB.Where("partner_id = ?", partnerID).Order("id ASC").Find(&keys).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch API keys")
+	}
+
+	formattedKeys := []map[string]interface{}{}
+	for _, key := range keys {
+		formattedKeys = append(formattedKeys, formatAPIKey(key))
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": formattedKeys,
+	})
+}
+
+// RevokeAPIKey handler
+func (h *Handler) RevokeAPIKey(c echo.Context) error {
+	keyID := c.Param("id")
+
+	var key models.APIKey
+	if err := h.DB.First(&key, keyID).Error; err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "API key not found")
+	}
+
+	if err := h.DB.Model(&key).Update("revoked_at", time.Now()).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke API key")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "API key revoked",
+	})
+}
+
+// PartnerCreateRental handler
+func (h *Handler) PartnerCreateRental(c echo.Context) error {
+	partnerID := c.Get("partnerID").(uint)
+
+	var req PartnerRentalRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	var partner models.Partner
+	if err := h.DB.First(&partner, partnerID).Error; err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Partner not found")
+	}
+
+	// Partners only book for customers who approved them, unknown emails get the same answer
+	notAuthorized := echo.NewHTTPError(http.StatusForbidden, "Customer has not authorized bookings by this partner")
+	user, err := h.Users.FindByEmail(req.CustomerEmail)
+	if err != nil {
+		return notAuthorized
+	}
+	var link models.PartnerCustomer
+	if err := h.DB.Where("partner_id = ? AND user_id = ?", partner.ID, user.ID).First(&link).Error; err != nil || !link.Active() {
+		return notAuthorized
+	}
+	if user.VerifiedAt == nil {
+		return echo.NewHTTPError(http.StatusForbidden, "Customer email is not verified")
+	}
+
+	// The partner pays the invoice
+	return h.bookRental(c, *user, req.CreateRentalRequest, &partner.ID, partner.ContactEmail)
+}
+
+// PartnerGetRentals handler
+func (h *Handler) PartnerGetRentals(c echo.Context) error {
+	partnerID := c.Get("partnerID").(uint)
+
+	rentals, err := h.Rentals.ListByPartner(partnerID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch rentals")
+	}
+
+	formattedRentals := []map[string]interface{}{}
+	for _, rental := range rentals {
+		formattedRentals = append(formattedRentals, formatPartnerRental(rental))
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": formattedRentals,
+	})
+}
+
+// PartnerRequestCustomer handler asks a customer to authorize bookings by the partner
+func (h *Handler) PartnerRequestCustomer(c echo.Context) error {
+	partnerID := c.Get("partnerID").(uint)
+
+	var req PartnerCustomerRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	// Same response whether or not the customer exists
+	response := map[string]string{
+		"message": "If the customer is registered, they have been asked to authorize your bookings",
+	}
+
+	var partner models.Partner
+	if err := h.DB.First(&partner, partnerID).Error; err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Partner not found")
+	}
+
+	var user models.User
+	if err := h.DB.Where("LOWER(email) = ?", services.NormalizeEmail(req.CustomerEmail)).First(&user).Error; err != nil {
+		return c.JSON(http.StatusAccepted, response)
+	}
+
+	var link models.PartnerCustomer
+	err := h.DB.Where("partner_id = ? AND user_id = ?", partner.ID, user.ID).First(&link).Error
+	if err == nil && link.RevokedAt == nil {
+		// Already pending or approved
+		return c.JSON(http.StatusAccepted, response)
+	}
+
+	tx := h.DB.Begin()
+
+	if err == nil {
+		// A revoked customer is asked again
+		err = tx.Model(&link).Updates(map[string]interface{}{"approved_at": nil, "revoked_at": nil}).Error
+	} else {
+		link = models.PartnerCustomer{PartnerID: partner.ID, UserID: user.ID}
+		err = tx.Create(&link).Error
+	}
+	if err != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to request authorization")
+	}
+
+	if err := h.notifyUser(tx, user, "security", "partner_access", map[string]interface{}{
+		"Partner": partner.Name,
+	}); err != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to request authorization")
+	}
+
+	if err := h.commitTx(tx); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to request authorization")
+	}
+
+	return c.JSON(http.StatusAccepted, response)
+}
+
+// PartnerGetCustomers handler lists the customers who authorized the partner
+func (h *Handler) PartnerGetCustomers(c echo.Context) error {
+	partnerID := c.Get("partnerID").(uint)
+
+	var links []models.PartnerCustomer
+	if err := h.DB.Preload("User").
+		Where("partner_id = ? AND approved_at IS NOT NULL AND revoked_at IS NULL", partnerID).
+		Order("id ASC").
+		Find(&links).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch customers")
+	}
+
+	formattedCustomers := []map[string]interface{}{}
+	for _, link := range links {
+		formattedCustomers = append(formattedCustomers, map[string]interface{}{
+			"id":          link.UserID,
+			"email":       link.User.Email,
+			"approved_at": link.ApprovedAt,
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": formattedCustomers,
+	})
+}
+
+// GetPartnerAccess handler lists the partners that asked for or hold the user's authorization
+func (h *Handler) GetPartnerAccess(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+
+	var links []models.PartnerCustomer
+	if err := h.DB.Preload("Partner").
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Order("id ASC").
+		Find(&links).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch partner access")
+	}
+
+	formattedLinks := []map[string]interface{}{}
+	for _, link := range links {
+		formattedLinks = append(formattedLinks, map[string]interface{}{
+			"id":           link.ID,
+			"partner_id":   link.PartnerID,
+			"partner_name": link.Partner.Name,
+			"approved_at":  link.ApprovedAt,
+			"requested_at": link.UpdatedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": formattedLinks,
+	})
+}
+
+// ApprovePartnerAccess handler lets a partner book rentals for the user
+func (h *Handler) ApprovePartnerAccess(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+
+	var link models.PartnerCustomer
+	if err := h.DB.Where("id = ? AND user_id = ? AND revoked_at IS NULL", c.Param("id"), userID).First(&link).Error; err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Partner request not found")
+	}
+
+	if link.ApprovedAt == nil {
+		if err := h.DB.Model(&link).Update("approved_at", time.Now()).Error; err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to approve partner")
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Partner can now book rentals for you",
+	})
+}
+
+// RevokePartnerAccess handler withdraws or declines a partner's authorization
+func (h *Handler) RevokePartnerAccess(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+
+	result := h.DB.Model(&models.PartnerCustomer{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", c.Param("id"), userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke partner")
+	}
+	if result.RowsAffected == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "Partner request not found")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Partner access revoked",
+	})
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"car-rental/internal/models"
+	"car-rental/internal/services"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const passwordResetTTL = time.Hour
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=6"`
+}
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=6"`
+}
+
+// passwordResetLink appends the token to the frontend page that asks for the new password,
+// which then posts both to /api/v1/password/reset
+func passwordResetLink(token string) string {
+	u, err := url.Parse(settings.App.PasswordResetURL)
+	if err != nil {
+		return settings.App.PasswordResetURL + "?token=" + url.QueryEscape(token)
+	}
+	query := u.Query()
+	query.Set("token", token)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// ForgotPassword handler
+func (h *Handler) ForgotPassword(c echo.Context) error {
+	var req ForgotPasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	// Same response whether or not the email exists
+	response := map[string]string{
+		"message": "If the email is registered, a reset link has been sent",
+	}
+
+	var user models.User
+	if err := h.DB.Where("LOWER(email) = ?", services.NormalizeEmail(req.Email)).First(&user).Error; err != nil {
+		return c.JSON(http.StatusOK, response)
+	}
+
+	raw, err := services.RandomToken(32)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate reset token")
+	}
+
+	tx := h.DB.Begin()
+
+	// Only the latest reset link stays valid
+	if err := tx.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", user.ID).
+		Update("used_at", time.Now()).Error; err != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create reset token")
+	}
+
+	resetToken := models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: services.HashToken(raw),
+		ExpiresAt: time.Now().Add(passwordResetTTL),
+	}
+	if err := tx.Create(&resetToken).Error; err != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create reset token")
+	}
+
+	if err := h.notifyUser(tx, user, "security", "password_reset", map[string]interface{}{
+		"URL": passwordResetLink(raw),
+	}); err != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to queue reset email")
+	}
+
+	if err := h.commitTx(tx); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create reset token")
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// ResetPassword handler
+func (h *Handler) ResetPassword(c echo.Context) error {
+	var req ResetPasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if len(req.NewPassword) < 6 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Password must be at least 6 characters")
+	}
+
+	var resetToken models.PasswordResetToken
+	if err := h.DB.Where("token_hash = ?", services.HashToken(req.Token)).First(&resetToken).Error; err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired reset token")
+	}
+
+	if resetToken.UsedAt != nil || time.Now().After(resetToken.ExpiresAt) {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired reset token")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to hash password")
+	}
+
+	tx := h.DB.Begin()
+
+	// Consume the token, guarding against concurrent use
+	result := tx.Model(&models.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", resetToken.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil || result.RowsAffected == 0 {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired reset token")
+	}
+
+	if err := tx.Model(&models.User{}).Where("id = ?", resetToken.UserID).
+		Update("password", string(hashedPassword)).Error; err != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update password")
+	}
+
+	if err := revokeUserSessions(tx, resetToken.UserID, 0); err != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke sessions")
+	}
+
+	var user models.User
+	if err := tx.First(&user, resetToken.UserID).Error; err != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+
+	if err := h.notifyUser(tx, user, "security", "password_changed", map[string]interface{}{
+		"Reset": true,
+	}); err != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to queue notification")
+	}
+
+	if err := h.commitTx(tx); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update password")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Password reset successful",
+	})
+}
+
+// ChangePassword handler
+func (h *Handler) ChangePassword(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+
+	var req ChangePasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if len(req.NewPassword) < 6 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Password must be at least 6 characters")
+	}
+
+	var user models.User
+	if err := h.DB.First(&user, userID).Error; err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+
+	// Check old password
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.OldPassword)); err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Old password is incorrect")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to hash password")
+	}
+
+	tx := h.DB.Begin()
+
+	if err := tx.Model(&user).Update("password", string(hashedPassword)).Error; err != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update password")
+	}
+
+	// Revoke every existing session, including the current access token
+	if err := revokeUserSessions(tx, user.ID, 0); err != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke sessions")
+	}
+
+	if err := tx.Create(&models.RevokedToken{
+		JTI:       c.Get("jti").(string),
+		ExpiresAt: c.Get("tokenExpiresAt").(time.Time),
+	}).Error; err != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke token")
+	}
+
+	// Issue a fresh session for the caller
+	session, err := createSession(tx, c, user.ID)
+	if err != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create session")
+	}
+
+	refreshToken, _, err := issueRefreshToken(tx, user.ID, session.ID)
+	if err != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate refresh token")
+	}
+
+	if err := h.notifyUser(tx, user, "security", "password_changed", map[string]interface{}{
+		"Reset": false,
+	}); err != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to queue notification")
+	}
+
+	if err := h.commitTx(tx); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update password")
+	}
+
+	token, err := services.GenerateJWT(user.ID, session.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate token")
+	}
+
+	response := tokenResponse(token, refreshToken)
+	response["message"] = "Password changed successfully"
+
+	return c.JSON(http.StatusOK, response)
+}
@@ -1,11 +1,10 @@
 package handlers
 
 import (
+	"car-rental/internal/events"
 	"car-rental/internal/models"
-	"car-rental/pkg/database"
 	"fmt"
 	"github.com/labstack/echo/v4"
-	"gorm.io/gorm"
 	"net/http"
 	"time"
 )
@@ -14,13 +13,13 @@ type CreatePaymentRequest struct {
 	Amount float64 `json:"amount" validate:"required,min=10000"`
 }
 
-func WebhookHandler(c echo.Context) error {
+func (h *Handler) WebhookHandler(c echo.Context) error {
 	// Log webhook data yang diterima
 	fmt.Println("----------------------------------------")
 	fmt.Println("Webhook received at:", time.Now())
 
 	callbackToken := c.Request().Header.Get("X-CALLBACK-TOKEN")
-	fmt.Printf("Callback token: %s\n", callbackToken)
+	fmt.Printf("Callback token present: %t\n", callbackToken != "")
 
 	var webhookData struct {
 		ExternalID string  `json:"external_id"`
@@ -40,64 +39,107 @@ func WebhookHandler(c echo.Context) error {
 	fmt.Printf("- Amount: %.2f\n", webhookData.Amount)
 	fmt.Printf("- ID: %s\n", webhookData.ID)
 
-	tx := database.DB.Begin()
+	tx := h.DB.Begin()
 
 	// Log query yang akan dijalankan
 	fmt.Printf("\nSearching for payment in database...\n")
 	fmt.Printf("Query: external_id = %s\n", webhookData.ExternalID)
 
 	var payment models.Payment
-	if err := tx.Where("external_id = ?", webhookData.ExternalID).First(&payment).Error; err != nil {
+	if err := tx.Preload("Rental").Where("external_id = ?", webhookData.ExternalID).First(&payment).Error; err != nil {
 		fmt.Printf("Error finding payment: %v\n", err)
-		tx.Rollback()
+		rollbackTx(tx)
 		return echo.NewHTTPError(http.StatusNotFound, "Payment not found")
 	}
 
 	fmt.Printf("Payment found! ID: %d\n", payment.ID)
 
+	previousStatus := payment.Status
+
 	// Log status update
 	fmt.Printf("\nUpdating payment status...\n")
 	if err := tx.Model(&payment).Updates(map[string]interface{}{
 		"status": webhookData.Status,
 	}).Error; err != nil {
 		fmt.Printf("Error updating payment: %v\n", err)
-		tx.Rollback()
+		rollbackTx(tx)
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update payment")
 	}
 
+	raiseEvent(tx, events.New(events.PaymentUpdated, payment.Rental.UserID, map[string]interface{}{
+		"payment_id": payment.ID,
+		"rental_id":  payment.RentalID,
+		"status":     webhookData.Status,
+	}))
+
 	if webhookData.Status == "PAID" {
 		fmt.Printf("\nPayment is PAID, updating rental and car...\n")
 
 		var rental models.RentalHistory
-		if err := tx.First(&rental, payment.RentalID).Error; err != nil {
+		if err := tx.Preload("Car").First(&rental, payment.RentalID).Error; err != nil {
 			fmt.Printf("Error finding rental: %v\n", err)
-			tx.Rollback()
+			rollbackTx(tx)
 			return echo.NewHTTPError(http.StatusNotFound, "Rental not found")
 		}
 
 		fmt.Printf("Found rental ID: %d\n", rental.ID)
 
-		// Update rental status
-		if err := tx.Model(&rental).Update("status", "active").Error; err != nil {
-			fmt.Printf("Error updating rental status: %v\n", err)
-			tx.Rollback()
+		// Update rental status, repeated PAID callbacks must not activate it twice
+		result := tx.Model(&models.RentalHistory{}).
+			Where("id = ? AND status = ?", rental.ID, "pending").
+			Update("status", "active")
+		if result.Error != nil {
+			fmt.Printf("Error updating rental status: %v\n", result.Error)
+			rollbackTx(tx)
 			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update rental")
 		}
 
-		// Update car stock
-		if err := tx.Model(&models.Car{}).Where("id = ?", rental.CarID).
-			UpdateColumn("stock_availability", gorm.Expr("stock_availability - ?", 1)).Error; err != nil {
-			fmt.Printf("Error updating car stock: %v\n", err)
-			tx.Rollback()
-			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update car stock")
+		if previousStatus != "PAID" {
+			raiseEvent(tx, events.New(events.PaymentPaid, rental.UserID, map[string]interface{}{
+				"payment_id": payment.ID,
+				"rental_id":  rental.ID,
+				"car_id":     rental.CarID,
+				"category":   rental.Car.Category,
+				"amount":     payment.Amount,
+			}))
+		}
+
+		// Car stock and the confirmation email follow from the event
+		if result.RowsAffected > 0 {
+			raiseEvent(tx, events.New(events.RentalActivated, rental.UserID, map[string]interface{}{
+				"rental_id": rental.ID,
+				"car_id":    rental.CarID,
+				"status":    "active",
+			}))
 		}
 
-		fmt.Printf("Successfully updated rental status and car stock\n")
+		fmt.Printf("Successfully updated rental status\n")
 	}
 
-	if err := tx.Commit().Error; err != nil {
+	// An expired invoice cancels the booking, the waitlist gets the car back
+	if webhookData.Status == "EXPIRED" {
+		result := tx.Model(&models.RentalHistory{}).
+			Where("id = ? AND status = ?", payment.RentalID, "pending").
+			Update("status", "cancelled")
+		if result.Error != nil {
+			fmt.Printf("Error cancelling rental: %v\n", result.Error)
+			rollbackTx(tx)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update rental")
+		}
+
+		if result.RowsAffected > 0 {
+			raiseEvent(tx, events.New(events.RentalCancelled, payment.Rental.UserID, map[string]interface{}{
+				"rental_id": payment.RentalID,
+				"car_id":    payment.Rental.CarID,
+				"status":    "cancelled",
+				"reason":    "payment_expired",
+			}))
+		}
+	}
+
+	if err := h.commitTx(tx); err != nil {
 		fmt.Printf("Error committing transaction: %v\n", err)
-		tx.Rollback()
+		rollbackTx(tx)
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to process webhook")
 	}
 
@@ -112,16 +154,11 @@ func WebhookHandler(c echo.Context) error {
 // GetPaymentHistory mengambil history pembayaran user
 // GetPaymentHistory handler
 // GetPaymentHistory mengambil history pembayaran user
-func GetPaymentHistory(c echo.Context) error {
+func (h *Handler) GetPaymentHistory(c echo.Context) error {
 	userID := c.Get("userID").(uint)
 
-	var payments []models.Payment
-	// Join dengan rental untuk filter by userID
-	if err := database.DB.Joins("JOIN rental_history ON rental_history.id = payments.rental_id").
-		Where("rental_history.user_id = ?", userID).
-		Preload("Rental").
-		Preload("Rental.Car").
-		Find(&payments).Error; err != nil {
+	payments, err := h.Payments.ListByUser(userID)
+	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch payment history")
 	}
 
@@ -131,16 +168,15 @@ func GetPaymentHistory(c echo.Context) error {
 }
 
 // GetPaymentDetail mengambil detail pembayaran tertentu
-func GetPaymentDetail(c echo.Context) error {
+func (h *Handler) GetPaymentDetail(c echo.Context) error {
 	userID := c.Get("userID").(uint)
-	paymentID := c.Param("id")
+	paymentID, ok := paramID(c, "id")
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "Payment not found")
+	}
 
-	var payment models.Payment
-	if err := database.DB.Preload("Rental").
-		Preload("Rental.Car").
-		Joins("JOIN rental_history ON rental_history.id = payments.rental_id").
-		Where("payments.id = ? AND rental_history.user_id = ?", paymentID, userID).
-		First(&payment).Error; err != nil {
+	payment, err := h.Payments.FindForUser(paymentID, userID)
+	if err != nil {
 		return echo.NewHTTPError(http.StatusNotFound, "Payment not found")
 	}
 
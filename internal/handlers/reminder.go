@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"car-rental/internal/models"
+	"fmt"
+	"time"
+)
+
+const reminderBatchSize = 100
+
+// SendRentalReminders queues pickup, return-due and daily overdue reminders for active rentals
+func (h *Handler) SendRentalReminders() {
+	now := time.Now()
+
+	// Pickup reminders ahead of the rental start
+	var pickups []models.RentalHistory
+	if err := h.DB.Preload("User").Preload("Car").
+		Where("status = ? AND pickup_reminded_at IS NULL AND rental_start > ? AND rental_start <= ?",
+			"active", now, now.Add(settings.Rentals.PickupLead)).
+		Limit(reminderBatchSize).
+		Find(&pickups).Error; err != nil {
+		fmt.Printf("Error fetching pickup reminders: %v\n", err)
+	}
+	for _, rental := range pickups {
+		h.queueReminder(rental, "pickup_reminded_at", "pickup_reminder", map[string]interface{}{
+			"Car":   rental.Car.Name,
+			"Start": rental.RentalStart.Format("2006-01-02"),
+		}, "pickup_reminded_at IS NULL")
+	}
+
+	// Return reminders ahead of the rental end
+	var returns []models.RentalHistory
+	if err := h.DB.Preload("User").Preload("Car").
+		Where("status = ? AND return_reminded_at IS NULL AND rental_end > ? AND rental_end <= ?",
+			"active", now, now.Add(settings.Rentals.ReturnLead)).
+		Limit(reminderBatchSize).
+		Find(&returns).Error; err != nil {
+		fmt.Printf("Error fetching return reminders: %v\n", err)
+	}
+	for _, rental := range returns {
+		h.queueReminder(rental, "return_reminded_at", "return_reminder", map[string]interface{}{
+			"Car": rental.Car.Name,
+			"End": rental.RentalEnd.Format("2006-01-02"),
+		}, "return_reminded_at IS NULL")
+	}
+
+	// Overdue notices, at most once a day until the car is returned. rental_end
+	// is stored at midnight and the car may be kept until the end of that day.
+	dayAgo := now.Add(-24 * time.Hour)
+	var overdue []models.RentalHistory
+	if err := h.DB.Preload("User").Preload("Car").
+		Where("status = ? AND rental_end + interval '1 day' <= ? AND (overdue_notified_at IS NULL OR overdue_notified_at <= ?)",
+			"active", now, dayAgo).
+		Limit(reminderBatchSize).
+		Find(&overdue).Error; err != nil {
+		fmt.Printf("Error fetching overdue rentals: %v\n", err)
+	}
+	for _, rental := range overdue {
+		h.queueReminder(rental, "overdue_notified_at", "rental_overdue", map[string]interface{}{
+			"Car":  rental.Car.Name,
+			"End":  rental.RentalEnd.Format("2006-01-02"),
+			"Days": overdueDays(rental.RentalEnd, now),
+		}, "(overdue_notified_at IS NULL OR overdue_notified_at <= ?)", dayAgo)
+	}
+}
+
+// overdueDays counts the days a rental is overdue at now, starting at 1 on the
+// day after rental_end since the car may be kept until the end of that day.
+// It is 0 while the rental is not overdue.
+func overdueDays(rentalEnd, now time.Time) int {
+	due := rentalEnd.AddDate(0, 0, 1)
+	if now.Before(due) {
+		return 0
+	}
+	return int(now.Sub(due).Hours()/24) + 1
+}
+
+// queueReminder sets the marker and queues the notification in one transaction.
+// The guard re-checks the marker so a reminder is never queued twice, even
+// across restarts or with several instances running.
+func (h *Handler) queueReminder(rental models.RentalHistory, marker, templateName string, data map[string]interface{}, guard string, guardArgs ...interface{}) {
+	tx := h.DB.Begin()
+
+	result := tx.Model(&models.RentalHistory{}).
+		Where("id = ? AND status = ?", rental.ID, "active").
+		Where(guard, guardArgs...).
+		Update(marker, time.Now())
+	if result.Error != nil {
+		rollbackTx(tx)
+		fmt.Printf("Error marking %s for rental %d: %v\n", templateName, rental.ID, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		rollbackTx(tx)
+		return
+	}
+
+	if err := h.notifyUser(tx, rental.User, "reminder", templateName, data); err != nil {
+		rollbackTx(tx)
+		fmt.Printf("Error queueing %s for rental %d: %v\n", templateName, rental.ID, err)
+		return
+	}
+
+	if err := h.commitTx(tx); err != nil {
+		fmt.Printf("Error queueing %s for rental %d: %v\n", templateName, rental.ID, err)
+	}
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"car-rental/internal/models"
+	"car-rental/pkg/database"
+	"testing"
+	"time"
+)
+
+func TestOverdueDays(t *testing.T) {
+	end := time.Date(2026, 10, 23, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want int
+	}{
+		{"before the last day", end.Add(-time.Hour), 0},
+		{"on the last day", end.Add(23 * time.Hour), 0},
+		{"last second of the last day", end.Add(24*time.Hour - time.Second), 0},
+		{"day after at midnight", end.AddDate(0, 0, 1), 1},
+		{"day after in the evening", end.AddDate(0, 0, 1).Add(20 * time.Hour), 1},
+		{"two days after", end.AddDate(0, 0, 2), 2},
+		{"a week after", end.AddDate(0, 0, 7).Add(time.Hour), 7},
+	}
+
+	for _, tt := range tests {
+		if got := overdueDays(end, tt.now); got != tt.want {
+			t.Errorf("%s: overdueDays = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSendRentalRemindersWindows(t *testing.T) {
+	h := useTestDB(t)
+	user := createRenter(t)
+	car := models.Car{Name: "Avanza", StockAvailability: 5, RentalCosts: 300000}
+	if err := database.DB.Create(&car).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	rentals := map[string]*models.RentalHistory{
+		"pickup soon":     {RentalStart: now.Add(settings.Rentals.PickupLead / 2), RentalEnd: now.AddDate(0, 0, 5)},
+		"pickup later":    {RentalStart: now.Add(settings.Rentals.PickupLead * 2), RentalEnd: now.AddDate(0, 0, 5)},
+		"return soon":     {RentalStart: now.AddDate(0, 0, -3), RentalEnd: now.Add(settings.Rentals.ReturnLead / 2)},
+		"ends today":      {RentalStart: today.AddDate(0, 0, -3), RentalEnd: today},
+		"ended yesterday": {RentalStart: today.AddDate(0, 0, -4), RentalEnd: today.AddDate(0, 0, -1)},
+	}
+	for _, rental := range rentals {
+		rental.UserID, rental.CarID, rental.TotalCost, rental.Status = user.ID, car.ID, 300000, "active"
+		if err := database.DB.Create(rental).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h.SendRentalReminders()
+	h.SendRentalReminders() // markers keep a second run from queueing again
+
+	want := map[string][3]bool{ // pickup, return, overdue
+		"pickup soon":     {true, false, false},
+		"pickup later":    {false, false, false},
+		"return soon":     {false, true, false},
+		"ends today":      {false, false, false},
+		"ended yesterday": {false, false, true},
+	}
+	for name, rental := range rentals {
+		var got models.RentalHistory
+		if err := database.DB.First(&got, rental.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		marked := [3]bool{got.PickupRemindedAt != nil, got.ReturnRemindedAt != nil, got.OverdueNotifiedAt != nil}
+		if marked != want[name] {
+			t.Errorf("%s: pickup/return/overdue reminded = %v, want %v", name, marked, want[name])
+		}
+	}
+
+	var queued int64
+	if err := database.DB.Model(&models.UserNotification{}).
+		Where("user_id = ? AND type = ?", user.ID, "reminder").
+		Count(&queued).Error; err != nil {
+		t.Fatal(err)
+	}
+	if queued != 3 {
+		t.Fatalf("%d reminders recorded, want 3", queued)
+	}
+}
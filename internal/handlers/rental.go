@@ -1,11 +1,12 @@
 package handlers
 
 import (
+	"car-rental/internal/events"
 	"car-rental/internal/models"
-	"car-rental/internal/services"
-	"car-rental/pkg/database"
+	"car-rental/internal/repository"
 	"fmt"
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm/clause"
 	"net/http"
 	"time"
 )
@@ -17,7 +18,7 @@ type CreateRentalRequest struct {
 }
 
 // CreateRental handler
-func CreateRental(c echo.Context) error {
+func (h *Handler) CreateRental(c echo.Context) error {
 	userID := c.Get("userID").(uint)
 
 	var req CreateRentalRequest
@@ -26,11 +27,18 @@ func CreateRental(c echo.Context) error {
 	}
 
 	// Get user data
-	var user models.User
-	if err := database.DB.First(&user, userID).Error; err != nil {
+	user, err := h.Users.FindByID(userID)
+	if err != nil {
 		return echo.NewHTTPError(http.StatusNotFound, "User not found")
 	}
 
+	return h.bookRental(c, *user, req, nil, user.Email)
+}
+
+// bookRental creates the rental and its payment invoice, partnerID is set for partner bookings
+func (h *Handler) bookRental(c echo.Context, user models.User, req CreateRentalRequest, partnerID *uint, payerEmail string) error {
+	userID := user.ID
+
 	// Parse rental dates
 	rentalStart, err := time.Parse("2006-01-02", req.RentalStart)
 	if err != nil {
@@ -42,12 +50,30 @@ func CreateRental(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid rental end date format. Use YYYY-MM-DD")
 	}
 
+	// Licence must be verified and valid for the whole rental
+	if err := checkRentalKYC(user, rentalEnd); err != nil {
+		return err
+	}
+
 	// Get car data
-	var car models.Car
-	if err := database.DB.First(&car, req.CarID).Error; err != nil {
+	car, err := h.Cars.FindByID(req.CarID)
+	if err != nil {
 		return echo.NewHTTPError(http.StatusNotFound, "Car not found")
 	}
 
+	// Check availability, a waitlist hold reserves a unit for its holder.
+	// Checked again under the car lock below, this only fails fast.
+	hold := h.findWaitlistHold(userID, car.ID)
+	if hold == nil {
+		free, err := availableUnits(h.Waitlist, *car)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check availability")
+		}
+		if free <= 0 {
+			return echo.NewHTTPError(http.StatusConflict, "Car is not available, join the waitlist instead")
+		}
+	}
+
 	// Calculate total cost
 	days := int(rentalEnd.Sub(rentalStart).Hours() / 24)
 	if days == 0 {
@@ -63,21 +89,70 @@ func CreateRental(c echo.Context) error {
 		RentalEnd:   rentalEnd,
 		TotalCost:   totalCost,
 		Status:      "pending",
+		PartnerID:   partnerID,
 	}
 
-	if err := database.DB.Create(&rental).Error; err != nil {
+	// Lock the car so parallel bookings see each other's holds and rentals,
+	// then check availability again now that nobody else can take the unit
+	tx := h.DB.Begin()
+	repos := repository.NewGorm(tx)
+
+	var locked models.Car
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, car.ID).Error; err != nil {
+		rollbackTx(tx)
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create rental")
 	}
 
-	// Preload User and Car for response
-	if err := database.DB.Preload("User").Preload("Car").First(&rental, rental.ID).Error; err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load rental data")
+	hold, err = repos.Waitlist.FindHold(userID, car.ID, time.Now())
+	if err != nil && err != repository.ErrNotFound {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check availability")
+	}
+	if hold == nil {
+		free, err := availableUnits(repos.Waitlist, locked)
+		if err != nil {
+			rollbackTx(tx)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check availability")
+		}
+		if free <= 0 {
+			rollbackTx(tx)
+			return echo.NewHTTPError(http.StatusConflict, "Car is not available, join the waitlist instead")
+		}
 	}
 
-	// Create payment invoice
-	paymentService := services.NewPaymentService()
-	invoice, err := paymentService.CreatePayment(user.Email, totalCost, rental.ID)
+	if err := tx.Create(&rental).Error; err != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create rental")
+	}
+
+	// Convert the waitlist hold into this rental, unless it expired meanwhile
+	if hold != nil {
+		result := tx.Model(&models.CarWaitlist{}).
+			Where("id = ? AND status = ? AND hold_expires_at > ?", hold.ID, "offered", time.Now()).
+			Updates(map[string]interface{}{
+				"status":    "converted",
+				"rental_id": rental.ID,
+			})
+		if result.Error != nil {
+			rollbackTx(tx)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create rental")
+		}
+		if result.RowsAffected == 0 {
+			rollbackTx(tx)
+			return echo.NewHTTPError(http.StatusConflict, "Waitlist hold has expired")
+		}
+	}
+
+	// The pending rental reserves the unit before the gateway is called, so the
+	// car row is not locked while waiting on the network
+	if err := h.commitTx(tx); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create rental")
+	}
+
+	// Create payment invoice, a failure cancels the rental and frees the unit again
+	invoice, err := h.PaymentGateway.CreatePayment(payerEmail, totalCost, rental.ID)
 	if err != nil {
+		h.cancelPendingRental(rental, "invoice_failed")
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create payment invoice")
 	}
 
@@ -93,14 +168,45 @@ func CreateRental(c echo.Context) error {
 		UpdatedAt:  time.Now(),
 	}
 
-	if err := database.DB.Create(&payment).Error; err != nil {
+	tx = h.DB.Begin()
+
+	if err := tx.Create(&payment).Error; err != nil {
+		rollbackTx(tx)
+		h.cancelPendingRental(rental, "invoice_failed")
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save payment data")
 	}
 
+	raiseEvent(tx, events.New(events.RentalCreated, userID, map[string]interface{}{
+		"rental_id":   rental.ID,
+		"car_id":      rental.CarID,
+		"status":      rental.Status,
+		"payment_id":  payment.ID,
+		"payment_url": payment.PaymentURL,
+		"amount":      payment.Amount,
+	}))
+
+	if err := h.commitTx(tx); err != nil {
+		h.cancelPendingRental(rental, "invoice_failed")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save payment data")
+	}
+
+	// Preload User and Car for response
+	loaded, err := h.Rentals.FindByID(rental.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load rental data")
+	}
+	rental = *loaded
+
+	// Partners see the rental without the customer's profile
+	var rentalData interface{} = rental
+	if partnerID != nil {
+		rentalData = formatPartnerRental(rental)
+	}
+
 	// Response
 	return c.JSON(http.StatusCreated, map[string]interface{}{
 		"message": "Rental created, waiting for payment",
-		"rental":  rental,
+		"rental":  rentalData,
 		"payment": map[string]interface{}{
 			"payment_url": invoice.InvoiceURL,
 			"amount":      invoice.Amount,
@@ -109,16 +215,40 @@ func CreateRental(c echo.Context) error {
 	})
 }
 
+// cancelPendingRental cancels a rental whose invoice could not be created.
+// The cancellation frees its unit, which goes back to the waitlist.
+func (h *Handler) cancelPendingRental(rental models.RentalHistory, reason string) {
+	tx := h.DB.Begin()
+
+	result := tx.Model(&models.RentalHistory{}).
+		Where("id = ? AND status = ?", rental.ID, "pending").
+		Update("status", "cancelled")
+	if result.Error != nil {
+		rollbackTx(tx)
+		fmt.Printf("Error cancelling rental %d: %v\n", rental.ID, result.Error)
+		return
+	}
+
+	if result.RowsAffected > 0 {
+		raiseEvent(tx, events.New(events.RentalCancelled, rental.UserID, map[string]interface{}{
+			"rental_id": rental.ID,
+			"car_id":    rental.CarID,
+			"status":    "cancelled",
+			"reason":    reason,
+		}))
+	}
+
+	if err := h.commitTx(tx); err != nil {
+		fmt.Printf("Error cancelling rental %d: %v\n", rental.ID, err)
+	}
+}
+
 // GetUserRentals handler
-func GetUserRentals(c echo.Context) error {
+func (h *Handler) GetUserRentals(c echo.Context) error {
 	userID := c.Get("userID").(uint)
 
-	var rentals []models.RentalHistory
-	if err := database.DB.
-		Preload("Car").
-		Preload("User").
-		Where("user_id = ?", userID).
-		Find(&rentals).Error; err != nil {
+	rentals, err := h.Rentals.ListByUser(userID)
+	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch rentals")
 	}
 
@@ -128,12 +258,15 @@ func GetUserRentals(c echo.Context) error {
 }
 
 // ReturnCar handler
-func ReturnCar(c echo.Context) error {
+func (h *Handler) ReturnCar(c echo.Context) error {
 	userID := c.Get("userID").(uint)
-	rentalID := c.Param("id")
+	rentalID, ok := paramID(c, "id")
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "Rental not found")
+	}
 
-	var rental models.RentalHistory
-	if err := database.DB.Preload("Car").Preload("User").First(&rental, rentalID).Error; err != nil {
+	rental, err := h.Rentals.FindByID(rentalID)
+	if err != nil {
 		return echo.NewHTTPError(http.StatusNotFound, "Rental not found")
 	}
 
@@ -148,32 +281,33 @@ func ReturnCar(c echo.Context) error {
 	}
 
 	// Begin transaction
-	tx := database.DB.Begin()
+	tx := h.DB.Begin()
 
-	// Update rental status
-	if err := tx.Model(&rental).Update("status", "completed").Error; err != nil {
-		tx.Rollback()
+	// Update rental status, only once even if the return is sent twice
+	result := tx.Model(&models.RentalHistory{}).
+		Where("id = ? AND status = ?", rental.ID, "active").
+		Update("status", "completed")
+	if result.Error != nil {
+		rollbackTx(tx)
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update rental")
 	}
-
-	// Update car availability
-	if err := tx.Model(&rental.Car).Update("stock_availability", rental.Car.StockAvailability+1).Error; err != nil {
-		tx.Rollback()
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update car availability")
+	if result.RowsAffected == 0 {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusBadRequest, "Rental is not active")
 	}
 
+	// Stock, the waitlist and the confirmation email follow from the event
+	raiseEvent(tx, events.New(events.CarReturned, rental.UserID, map[string]interface{}{
+		"rental_id":   rental.ID,
+		"car_id":      rental.CarID,
+		"status":      "completed",
+		"returned_at": time.Now().Format("2006-01-02"),
+	}))
+
 	// Commit transaction
-	tx.Commit()
-
-	// Send email notification
-	emailService := services.NewEmailService()
-	go emailService.SendEmail(
-		rental.User.Email,
-		"Car Return Confirmation",
-		fmt.Sprintf("You have successfully returned %s on %s",
-			rental.Car.Name,
-			time.Now().Format("2006-01-02")),
-	)
+	if err := h.commitTx(tx); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to return car")
+	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"message": "Car returned successfully",
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"car-rental/internal/models"
+	"car-rental/internal/services"
+	"car-rental/pkg/database"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// failingGateway cannot create invoices
+type failingGateway struct{}
+
+func (failingGateway) CreatePayment(userEmail string, amount float64, rentalID uint) (*services.Invoice, error) {
+	return nil, errors.New("gateway unavailable")
+}
+
+func (failingGateway) Ping(ctx context.Context) error {
+	return errors.New("gateway unavailable")
+}
+
+// createRenter stores a user who passed KYC and can rent
+func createRenter(t *testing.T) models.User {
+	t.Helper()
+
+	user := createTestUser(t)
+	expiry := time.Now().AddDate(5, 0, 0)
+	if err := database.DB.Model(&user).Updates(map[string]interface{}{
+		"kyc_status":     "approved",
+		"license_expiry": expiry,
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	user.KYCStatus = "approved"
+	user.LicenseExpiry = &expiry
+	return user
+}
+
+func TestBookRentalCancelsWhenInvoiceFails(t *testing.T) {
+	h := useTestDB(t)
+	h.PaymentGateway = failingGateway{}
+	holder := createRenter(t)
+	next := createTestUser(t)
+
+	car := models.Car{Name: "Brio", StockAvailability: 1, RentalCosts: 200000}
+	if err := database.DB.Create(&car).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// The only unit is held for holder, next waits behind
+	expiresAt := time.Now().Add(time.Hour)
+	start, end := time.Now().AddDate(0, 0, 1), time.Now().AddDate(0, 0, 3)
+	hold := models.CarWaitlist{UserID: holder.ID, CarID: car.ID, RentalStart: start, RentalEnd: end, Status: "offered", HoldExpiresAt: &expiresAt}
+	waiting := models.CarWaitlist{UserID: next.ID, CarID: car.ID, RentalStart: start, RentalEnd: end, Status: "waiting"}
+	for _, entry := range []*models.CarWaitlist{&hold, &waiting} {
+		if err := database.DB.Create(entry).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	body := `{"car_id":` + fmt.Sprint(car.ID) + `,"rental_start":"` + start.Format("2006-01-02") + `","rental_end":"` + end.Format("2006-01-02") + `"}`
+	expectStatus(t, userRequest(t, h.CreateRental, holder.ID, http.MethodPost, 0, body), http.StatusInternalServerError)
+
+	var rental models.RentalHistory
+	if err := database.DB.Where("user_id = ? AND car_id = ?", holder.ID, car.ID).First(&rental).Error; err != nil {
+		t.Fatal(err)
+	}
+	if rental.Status != "cancelled" {
+		t.Errorf("rental status = %s, want cancelled", rental.Status)
+	}
+
+	// The converted hold no longer reserves the unit, it goes to the next in line
+	database.DB.First(&waiting, waiting.ID)
+	if waiting.Status != "offered" {
+		t.Errorf("next waitlist entry is %s, want offered", waiting.Status)
+	}
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"car-rental/internal/models"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// describeDevice turns a user agent into a short "Browser on OS" label
+func describeDevice(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+
+	browser := "Unknown browser"
+	switch {
+	case strings.Contains(ua, "edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "opr/") || strings.Contains(ua, "opera"):
+		browser = "Opera"
+	case strings.Contains(ua, "chrome/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "safari/"):
+		browser = "Safari"
+	case strings.Contains(ua, "okhttp") || strings.Contains(ua, "dart"):
+		browser = "Mobile app"
+	case strings.Contains(ua, "curl") || strings.Contains(ua, "postman"):
+		browser = "API client"
+	}
+
+	platform := "unknown OS"
+	switch {
+	case strings.Contains(ua, "android"):
+		platform = "Android"
+	case strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad"):
+		platform = "iOS"
+	case strings.Contains(ua, "windows"):
+		platform = "Windows"
+	case strings.Contains(ua, "mac os"):
+		platform = "macOS"
+	case strings.Contains(ua, "linux"):
+		platform = "Linux"
+	}
+
+	return browser + " on " + platform
+}
+
+// createSession records a new login session for the request's device
+func createSession(tx *gorm.DB, c echo.Context, userID uint) (*models.UserSession, error) {
+	session := models.UserSession{
+		UserID:     userID,
+		Device:     describeDevice(c.Request().UserAgent()),
+		UserAgent:  c.Request().UserAgent(),
+		IPAddress:  c.RealIP(),
+		LastSeenAt: time.Now(),
+	}
+
+	if err := tx.Create(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// revokeSession revokes a session and every refresh token issued for it
+func revokeSession(tx *gorm.DB, sessionID uint) error {
+	now := time.Now()
+	if err := tx.Model(&models.UserSession{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.RefreshToken{}).
+		Where("session_id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", now).Error
+}
+
+// revokeUserSessions revokes every session of the user except keepSessionID (0 keeps none)
+func revokeUserSessions(tx *gorm.DB, userID, keepSessionID uint) error {
+	now := time.Now()
+	if err := tx.Model(&models.UserSession{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keepSessionID).
+		Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND session_id <> ? AND revoked_at IS NULL", userID, keepSessionID).
+		Update("revoked_at", now).Error
+}
+
+// ListSessions handler
+func (h *Handler) ListSessions(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+	currentSessionID := c.Get("sessionID").(uint)
+
+	var sessions []models.UserSession
+	if err := h.DB.
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch sessions")
+	}
+
+	formattedSessions := []map[string]interface{}{}
+	for _, session := range sessions {
+		formattedSessions = append(formattedSessions, map[string]interface{}{
+			"id":           session.ID,
+			"device":       session.Device,
+			"user_agent":   session.UserAgent,
+			"ip_address":   session.IPAddress,
+			"current":      session.ID == currentSessionID,
+			"created_at":   session.CreatedAt.Format("2006-01-02 15:04:05"),
+			"last_seen_at": session.LastSeenAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": formattedSessions,
+	})
+}
+
+// RevokeSession handler
+func (h *Handler) RevokeSession(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+	sessionID := c.Param("id")
+
+	var session models.UserSession
+	if err := h.DB.Where("id = ? AND user_id = ?", sessionID, userID).First(&session).Error; err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Session not found")
+	}
+
+	if err := revokeSession(h.DB, session.ID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke session")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Session revoked",
+	})
+}
+
+// RevokeOtherSessions handler
+func (h *Handler) RevokeOtherSessions(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+	currentSessionID := c.Get("sessionID").(uint)
+
+	if err := revokeUserSessions(h.DB, userID, currentSessionID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke sessions")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "All other sessions revoked",
+	})
+}
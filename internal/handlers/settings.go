@@ -0,0 +1,13 @@
+package handlers
+
+import (
+	"car-rental/internal/config"
+)
+
+// settings holds the configuration read by the handlers, defaults until Configure is called
+var settings = config.Defaults()
+
+// Configure sets the configuration used by the handlers, call it once at startup
+func Configure(cfg *config.Config) {
+	settings = *cfg
+}
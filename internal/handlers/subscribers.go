@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"car-rental/internal/events"
+	"car-rental/internal/metrics"
+	"car-rental/internal/models"
+	"encoding/json"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RegisterSubscribers wires the in-process reactions to domain events.
+// Stock, waitlist offers, notifications, webhooks and the audit log are written
+// in the transaction that raised the event, in registration order so stock
+// comes before the waitlist. Only analytics and metrics, which may be lost,
+// run on the bus after the commit.
+func (h *Handler) RegisterSubscribers(bus *events.Bus) {
+	h.onTx(updateStock, events.RentalActivated, events.CarReturned)
+	h.onTx(h.offerFreedCar, events.CarReturned, events.RentalCancelled)
+	h.onTx(h.sendEventNotification,
+		events.UserRegistered,
+		events.EmailVerified,
+		events.KYCReviewed,
+		events.TopUpCompleted,
+		events.RentalCreated,
+		events.RentalActivated,
+		events.CarReturned,
+	)
+	h.onTx(queueWebhooks, webhookEventTypes...)
+	h.onTx(recordAudit)
+
+	bus.Subscribe(h.countEvent)
+	bus.On(recordMetrics,
+		events.RentalCreated,
+		events.RentalActivated,
+		events.CarReturned,
+		events.PaymentPaid,
+		events.TopUpCompleted,
+	)
+}
+
+// updateStock takes a unit out on activation and puts it back on return
+func updateStock(tx *gorm.DB, e events.Event) error {
+	delta := -1
+	if e.Type == events.CarReturned {
+		delta = 1
+	}
+
+	if err := tx.Model(&models.Car{}).Where("id = ?", e.Uint("car_id")).
+		UpdateColumn("stock_availability", gorm.Expr("stock_availability + ?", delta)).Error; err != nil {
+		return fmt.Errorf("update stock for car %d: %w", e.Uint("car_id"), err)
+	}
+	return nil
+}
+
+// offerFreedCar offers a unit freed by a return or a cancelled booking to the waitlist
+func (h *Handler) offerFreedCar(tx *gorm.DB, e events.Event) error {
+	return h.offerHolds(tx, e.Uint("car_id"))
+}
+
+// sendEventNotification queues the email that belongs to an event
+func (h *Handler) sendEventNotification(tx *gorm.DB, e events.Event) error {
+	var user models.User
+	if err := tx.First(&user, e.UserID).Error; err != nil {
+		return fmt.Errorf("load user %d: %w", e.UserID, err)
+	}
+
+	var err error
+	switch e.Type {
+	case events.UserRegistered:
+		err = h.sendVerificationEmail(tx, user)
+	case events.EmailVerified:
+		err = h.notifyUser(tx, user, "registration", "welcome", nil)
+	case events.KYCReviewed:
+		templateName := "kyc_approved"
+		if e.String("status") == "rejected" {
+			templateName = "kyc_rejected"
+		}
+		err = h.notifyUser(tx, user, "kyc", templateName, map[string]interface{}{
+			"Reason": e.String("reason"),
+		})
+	case events.TopUpCompleted:
+		err = h.notifyUser(tx, user, "topup", "topup", map[string]interface{}{
+			"Amount":  e.Float("amount"),
+			"Balance": e.Float("balance"),
+		})
+	case events.RentalCreated, events.RentalActivated, events.CarReturned:
+		err = h.notifyRentalEvent(tx, user, e)
+	}
+
+	if err != nil {
+		return fmt.Errorf("queue notification: %w", err)
+	}
+	return nil
+}
+
+// notifyRentalEvent sends the rental emails, which need the rental and its car
+func (h *Handler) notifyRentalEvent(tx *gorm.DB, user models.User, e events.Event) error {
+	var rental models.RentalHistory
+	if err := tx.Preload("Car").First(&rental, e.Uint("rental_id")).Error; err != nil {
+		return err
+	}
+
+	start := rental.RentalStart.Format("2006-01-02")
+	end := rental.RentalEnd.Format("2006-01-02")
+
+	switch e.Type {
+	case events.RentalCreated:
+		return h.notifyUser(tx, user, "rental", "rental_created", map[string]interface{}{
+			"Car":        rental.Car.Name,
+			"Start":      start,
+			"End":        end,
+			"Amount":     e.Float("amount"),
+			"PaymentURL": e.String("payment_url"),
+		})
+	case events.RentalActivated:
+		var payment models.Payment
+		if err := tx.Where("rental_id = ?", rental.ID).First(&payment).Error; err != nil {
+			return err
+		}
+		return h.notifyUser(tx, user, "rental", "rental_activated", map[string]interface{}{
+			"Car":    rental.Car.Name,
+			"Start":  start,
+			"End":    end,
+			"Amount": payment.Amount,
+		})
+	default:
+		return h.notifyUser(tx, user, "return", "car_returned", map[string]interface{}{
+			"Car":  rental.Car.Name,
+			"Date": e.String("returned_at"),
+		})
+	}
+}
+
+// recordAudit persists every domain event, the log also feeds the event streams
+func recordAudit(tx *gorm.DB, e events.Event) error {
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		return fmt.Errorf("encode audit data: %w", err)
+	}
+
+	entry := models.AuditLog{
+		EventType:  e.Type,
+		Data:       string(data),
+		OccurredAt: e.OccurredAt,
+	}
+	if e.UserID != 0 {
+		userID := e.UserID
+		entry.UserID = &userID
+	}
+
+	if err := tx.Create(&entry).Error; err != nil {
+		return fmt.Errorf("record audit log: %w", err)
+	}
+	return nil
+}
+
+// countEvent keeps daily event counts for analytics
+func (h *Handler) countEvent(e events.Event) {
+	y, m, d := e.OccurredAt.UTC().Date()
+	count := models.EventCount{
+		Day:       time.Date(y, m, d, 0, 0, 0, 0, time.UTC),
+		EventType: e.Type,
+		Count:     1,
+	}
+
+	if err := h.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "day"}, {Name: "event_type"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"count": gorm.Expr("event_counts.count + 1")}),
+	}).Create(&count).Error; err != nil {
+		fmt.Printf("Error counting %s: %v\n", e.Type, err)
+	}
+}
+
+// recordMetrics updates the business counters exported at /metrics
+func recordMetrics(e events.Event) {
+	switch e.Type {
+	case events.RentalCreated:
+		metrics.RentalsCreated.Inc()
+	case events.RentalActivated:
+		metrics.RentalsActivated.Inc()
+	case events.CarReturned:
+		metrics.RentalsReturned.Inc()
+	case events.PaymentPaid:
+		category := e.String("category")
+		if category == "" {
+			category = "unknown"
+		}
+		if amount := e.Float("amount"); amount > 0 {
+			metrics.Revenue.WithLabelValues(category).Add(amount)
+		}
+	case events.TopUpCompleted:
+		metrics.TopUps.Inc()
+		if amount := e.Float("amount"); amount > 0 {
+			metrics.TopUpAmount.Add(amount)
+		}
+	}
+}
+
+// GetAuditLogs handler
+func (h *Handler) GetAuditLogs(c echo.Context) error {
+	query := h.DB.Model(&models.AuditLog{})
+	// In-app notifications are only kept for the event streams, unless asked for
+	if eventType := c.QueryParam("type"); eventType != "" {
+		query = query.Where("event_type = ?", eventType)
+	} else {
+		query = query.Where("event_type <> ?", events.NotificationCreated)
+	}
+	if userID := c.QueryParam("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var logs []models.AuditLog
+	if err := query.Order("occurred_at DESC").Limit(100).Find(&logs).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch audit logs")
+	}
+
+	formattedLogs := []map[string]interface{}{}
+	for _, log := range logs {
+		var data map[string]interface{}
+		json.Unmarshal([]byte(log.Data), &data)
+
+		formattedLogs = append(formattedLogs, map[string]interface{}{
+			"id":          log.ID,
+			"event_type":  log.EventType,
+			"user_id":     log.UserID,
+			"data":        data,
+			"occurred_at": log.OccurredAt,
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": formattedLogs,
+	})
+}
+
+// GetEventStats handler returns daily event counts
+func (h *Handler) GetEventStats(c echo.Context) error {
+	days, err := strconv.Atoi(c.QueryParam("days"))
+	if err != nil || days <= 0 || days > 365 {
+		days = 30
+	}
+	since := time.Now().UTC().AddDate(0, 0, -days+1)
+
+	var counts []models.EventCount
+	if err := h.DB.Where("day >= ?", since.Format("2006-01-02")).
+		Order("day ASC, event_type ASC").
+		Find(&counts).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch event stats")
+	}
+
+	totals := map[string]int64{}
+	for _, count := range counts {
+		totals[count.EventType] += count.Count
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data":   counts,
+		"totals": totals,
+	})
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"car-rental/internal/models"
+	"car-rental/internal/services"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+// errRefreshTokenReused means the token was already rotated, by an earlier or a concurrent request
+var errRefreshTokenReused = errors.New("refresh token reused")
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// issueRefreshToken stores a new hashed refresh token for a session
+func issueRefreshToken(tx *gorm.DB, userID, sessionID uint) (string, *models.RefreshToken, error) {
+	raw, err := services.RandomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+
+	token := models.RefreshToken{
+		UserID:    userID,
+		SessionID: sessionID,
+		TokenHash: services.HashToken(raw),
+		ExpiresAt: time.Now().Add(services.RefreshTokenTTL()),
+	}
+
+	if err := tx.Create(&token).Error; err != nil {
+		return "", nil, err
+	}
+
+	return raw, &token, nil
+}
+
+// rotateRefreshToken revokes current and issues its successor in tx. The
+// revocation is guarded on revoked_at IS NULL, so of two concurrent refreshes
+// with the same token only one wins and the other gets errRefreshTokenReused.
+func rotateRefreshToken(tx *gorm.DB, current models.RefreshToken) (string, error) {
+	result := tx.Model(&models.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", current.ID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if result.RowsAffected != 1 {
+		return "", errRefreshTokenReused
+	}
+
+	raw, next, err := issueRefreshToken(tx, current.UserID, current.SessionID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := tx.Model(&models.RefreshToken{}).Where("id = ?", current.ID).
+		Update("replaced_by_id", next.ID).Error; err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// tokenResponse builds the token pair returned to clients
+func tokenResponse(accessToken, refreshToken string) map[string]interface{} {
+	return map[string]interface{}{
+		"token":         accessToken,
+		"refresh_token": refreshToken,
+		"expires_in":    int(services.AccessTokenTTL().Seconds()),
+	}
+}
+
+// RefreshToken handler
+func (h *Handler) RefreshToken(c echo.Context) error {
+	var req RefreshTokenRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if req.RefreshToken == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Refresh token is required")
+	}
+
+	var current models.RefreshToken
+	if err := h.DB.Where("token_hash = ?", services.HashToken(req.RefreshToken)).First(&current).Error; err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid refresh token")
+	}
+
+	// A revoked token being presented again means it was stolen, kill the whole session
+	if current.RevokedAt != nil {
+		return h.refreshTokenReused(current.SessionID)
+	}
+
+	if time.Now().After(current.ExpiresAt) {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Refresh token expired")
+	}
+
+	// Session revoked from another device
+	var session models.UserSession
+	if err := h.DB.First(&session, current.SessionID).Error; err != nil || session.RevokedAt != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Session has been revoked")
+	}
+
+	// Rotate refresh token
+	tx := h.DB.Begin()
+
+	raw, err := rotateRefreshToken(tx, current)
+	if errors.Is(err, errRefreshTokenReused) {
+		rollbackTx(tx)
+		return h.refreshTokenReused(current.SessionID)
+	}
+	if err != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to rotate refresh token")
+	}
+
+	if err := tx.Model(&session).Update("last_seen_at", time.Now()).Error; err != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to rotate refresh token")
+	}
+
+	if err := h.commitTx(tx); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to rotate refresh token")
+	}
+
+	accessToken, err := services.GenerateJWT(current.UserID, current.SessionID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate token")
+	}
+
+	return c.JSON(http.StatusOK, tokenResponse(accessToken, raw))
+}
+
+// refreshTokenReused revokes the session of a reused refresh token
+func (h *Handler) refreshTokenReused(sessionID uint) error {
+	if err := revokeSession(h.DB, sessionID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke tokens")
+	}
+	return echo.NewHTTPError(http.StatusUnauthorized, "Refresh token reuse detected")
+}
+
+// Logout handler
+func (h *Handler) Logout(c echo.Context) error {
+	// Deny the current access token until it expires
+	revoked := models.RevokedToken{
+		JTI:       c.Get("jti").(string),
+		ExpiresAt: c.Get("tokenExpiresAt").(time.Time),
+	}
+	if err := h.DB.Create(&revoked).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke token")
+	}
+
+	// Revoke the session and its refresh tokens
+	if err := revokeSession(h.DB, c.Get("sessionID").(uint)); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke session")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Logged out successfully",
+	})
+}
+
+// PurgeRevokedTokens removes denylist entries for tokens that expired anyway
+func (h *Handler) PurgeRevokedTokens() {
+	h.DB.Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{})
+}
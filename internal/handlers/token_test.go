@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"car-rental/internal/models"
+	"car-rental/pkg/database"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestSession creates a session for user and returns its first refresh token
+func newTestSession(t *testing.T, user models.User) (models.UserSession, string, models.RefreshToken) {
+	t.Helper()
+
+	session := models.UserSession{UserID: user.ID, Device: "test", IPAddress: "127.0.0.1", LastSeenAt: time.Now()}
+	if err := database.DB.Create(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+	raw, token, err := issueRefreshToken(database.DB, user.ID, session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return session, raw, *token
+}
+
+func refresh(t *testing.T, h *Handler, token string) (int, string) {
+	t.Helper()
+
+	body, _ := json.Marshal(RefreshTokenRequest{RefreshToken: token})
+	rec := request(t, h.RefreshToken, http.MethodPost, string(body))
+
+	var response struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	return rec.Code, response.RefreshToken
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	h := useTestDB(t)
+	user := createTestUser(t)
+	session, first, _ := newTestSession(t, user)
+
+	status, second := refresh(t, h, first)
+	if status != http.StatusOK || second == "" || second == first {
+		t.Fatalf("first refresh: status %d, token %q", status, second)
+	}
+
+	status, third := refresh(t, h, second)
+	if status != http.StatusOK || third == "" {
+		t.Fatalf("second refresh: status %d", status)
+	}
+
+	// The rotated chain is linked
+	var rotated models.RefreshToken
+	database.DB.Where("session_id = ?", session.ID).Order("id ASC").First(&rotated)
+	if rotated.RevokedAt == nil || rotated.ReplacedByID == nil {
+		t.Errorf("first token was not revoked and linked: %+v", rotated)
+	}
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	h := useTestDB(t)
+	user := createTestUser(t)
+	session, first, _ := newTestSession(t, user)
+
+	status, second := refresh(t, h, first)
+	if status != http.StatusOK {
+		t.Fatalf("refresh: status %d", status)
+	}
+
+	// Replaying the old token kills the session, including the token that replaced it
+	if status, _ := refresh(t, h, first); status != http.StatusUnauthorized {
+		t.Fatalf("reuse: status %d, want 401", status)
+	}
+	if status, _ := refresh(t, h, second); status != http.StatusUnauthorized {
+		t.Fatalf("successor after reuse: status %d, want 401", status)
+	}
+
+	database.DB.First(&session, session.ID)
+	if session.RevokedAt == nil {
+		t.Error("session was not revoked")
+	}
+}
+
+func TestRotateRefreshTokenOnlyOnce(t *testing.T) {
+	h := useTestDB(t)
+	user := createTestUser(t)
+	_, _, current := newTestSession(t, user)
+
+	// Both requests loaded the token before either rotated it
+	const attempts = 5
+	var wg sync.WaitGroup
+	results := make(chan error, attempts)
+	start := make(chan struct{})
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+
+			tx := database.DB.Begin()
+			_, err := rotateRefreshToken(tx, current)
+			if err != nil {
+				rollbackTx(tx)
+			} else {
+				err = h.commitTx(tx)
+			}
+			results <- err
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(results)
+
+	rotated := 0
+	for err := range results {
+		switch {
+		case err == nil:
+			rotated++
+		case !errors.Is(err, errRefreshTokenReused):
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if rotated != 1 {
+		t.Fatalf("token rotated %d times, want exactly once", rotated)
+	}
+
+	var successors int64
+	database.DB.Model(&models.RefreshToken{}).Where("session_id = ? AND id <> ?", current.SessionID, current.ID).Count(&successors)
+	if successors != 1 {
+		t.Errorf("%d successor tokens stored, want 1", successors)
+	}
+}
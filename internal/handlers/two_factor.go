@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"car-rental/internal/models"
+	"car-rental/internal/services"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+type ConfirmTwoFactorRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+type LoginTwoFactorRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recovery_code"`
+}
+
+// verifyTOTP checks a code and rejects replays of an already used time step
+func verifyTOTP(tx *gorm.DB, user *models.User, code string) bool {
+	if user.TOTPSecret == nil {
+		return false
+	}
+
+	step, ok := services.ValidateTOTP(*user.TOTPSecret, code, time.Now())
+	if !ok || step <= user.TOTPLastStep {
+		return false
+	}
+
+	result := tx.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", user.ID, step).
+		Update("totp_last_step", step)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false
+	}
+	user.TOTPLastStep = step
+	return true
+}
+
+// useRecoveryCode consumes a matching unused recovery code
+func useRecoveryCode(tx *gorm.DB, userID uint, code string) bool {
+	result := tx.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, services.HashToken(services.NormalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	return result.Error == nil && result.RowsAffected > 0
+}
+
+// EnrollTwoFactor handler
+func (h *Handler) EnrollTwoFactor(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+
+	var user models.User
+	if err := h.DB.First(&user, userID).Error; err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+
+	if user.TOTPEnabledAt != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Two-factor authentication is already enabled")
+	}
+
+	// Pending secret, only active after confirmation
+	secret, err := services.GenerateTOTPSecret()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate secret")
+	}
+
+	if err := h.DB.Model(&user).Updates(map[string]interface{}{
+		"totp_secret":    secret,
+		"totp_last_step": 0,
+	}).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save secret")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"secret":      secret,
+		"otpauth_uri": services.TOTPURI(secret, user.Email, settings.Login.TOTPIssuer),
+	})
+}
+
+// ConfirmTwoFactor handler
+func (h *Handler) ConfirmTwoFactor(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+
+	var req ConfirmTwoFactorRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	var user models.User
+	if err := h.DB.First(&user, userID).Error; err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+
+	if user.TOTPEnabledAt != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Two-factor authentication is already enabled")
+	}
+	if user.TOTPSecret == nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Start enrolment first")
+	}
+
+	codes, err := services.GenerateRecoveryCodes()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate recovery codes")
+	}
+
+	tx := h.DB.Begin()
+
+	if !verifyTOTP(tx, &user, req.Code) {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid code")
+	}
+
+	if err := tx.Model(&user).Update("totp_enabled_at", time.Now()).Error; err != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to enable two-factor authentication")
+	}
+
+	// Replace any previous recovery codes
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save recovery codes")
+	}
+	for _, code := range codes {
+		if err := tx.Create(&models.RecoveryCode{
+			UserID:   user.ID,
+			CodeHash: services.HashToken(services.NormalizeRecoveryCode(code)),
+		}).Error; err != nil {
+			rollbackTx(tx)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save recovery codes")
+		}
+	}
+
+	if err := h.commitTx(tx); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to enable two-factor authentication")
+	}
+
+	// Recovery codes are only shown once
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTwoFactor handler
+func (h *Handler) DisableTwoFactor(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+
+	var req DisableTwoFactorRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	var user models.User
+	if err := h.DB.First(&user, userID).Error; err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+
+	if user.TOTPEnabledAt == nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Two-factor authentication is not enabled")
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid credentials")
+	}
+
+	tx := h.DB.Begin()
+
+	if !verifyTOTP(tx, &user, req.Code) && !useRecoveryCode(tx, user.ID, req.Code) {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid code")
+	}
+
+	if err := tx.Model(&user).Updates(map[string]interface{}{
+		"totp_secret":     nil,
+		"totp_enabled_at": nil,
+		"totp_last_step":  0,
+	}).Error; err != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to disable two-factor authentication")
+	}
+
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to disable two-factor authentication")
+	}
+
+	if err := h.commitTx(tx); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to disable two-factor authentication")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Two-factor authentication disabled",
+	})
+}
+
+// LoginTwoFactor handler
+func (h *Handler) LoginTwoFactor(c echo.Context) error {
+	var req LoginTwoFactorRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	userID, err := services.ParseTwoFactorChallenge(req.ChallengeToken)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired challenge")
+	}
+
+	var user models.User
+	if err := h.DB.First(&user, userID).Error; err != nil || user.TOTPEnabledAt == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired challenge")
+	}
+
+	// Codes are brute-forceable too, share the login limiter
+	ip := c.RealIP()
+	if wait, err := h.Limiter.CheckAccount(user.Email); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check login attempts")
+	} else if wait > 0 {
+		return tooManyAttempts(c, wait)
+	}
+
+	valid := false
+	if req.Code != "" {
+		valid = verifyTOTP(h.DB, &user, req.Code)
+	} else if req.RecoveryCode != "" {
+		valid = useRecoveryCode(h.DB, user.ID, req.RecoveryCode)
+	}
+
+	if !valid {
+		h.Limiter.RecordFailure(ip, user.Email)
+		h.recordLoginAttempt(c, user.Email, &user.ID, false)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid code")
+	}
+
+	h.Limiter.ResetAccount(user.Email)
+	h.recordLoginAttempt(c, user.Email, &user.ID, true)
+
+	return h.completeLogin(c, user)
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"car-rental/internal/models"
+	"car-rental/internal/services"
+	"car-rental/pkg/database"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// currentTOTP computes the code an authenticator app shows right now (RFC 6238)
+func currentTOTP(t *testing.T, secret string) string {
+	t.Helper()
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(time.Now().Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:offset+4])&0x7fffffff)%1000000)
+}
+
+func TestVerifyTOTPRejectsReplays(t *testing.T) {
+	useTestDB(t)
+	secret, err := services.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := createTestUser(t)
+	if err := database.DB.Model(&user).Update("totp_secret", secret).Error; err != nil {
+		t.Fatal(err)
+	}
+	user.TOTPSecret = &secret
+
+	code := currentTOTP(t, secret)
+	if !verifyTOTP(database.DB, &user, code) {
+		t.Fatal("first use of the current code was rejected")
+	}
+	if user.TOTPLastStep == 0 {
+		t.Fatal("totp_last_step was not recorded")
+	}
+
+	if verifyTOTP(database.DB, &user, code) {
+		t.Fatal("replayed code was accepted")
+	}
+
+	// A concurrent login that loaded the user before the first use
+	var stale models.User
+	if err := database.DB.First(&stale, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	stale.TOTPLastStep = 0
+	if verifyTOTP(database.DB, &stale, code) {
+		t.Fatal("code replayed with a stale totp_last_step was accepted")
+	}
+}
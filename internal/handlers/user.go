@@ -1,11 +1,10 @@
 package handlers
 
 import (
+	"car-rental/internal/events"
 	"car-rental/internal/models"
-	"car-rental/internal/services"
-	"car-rental/pkg/database"
-	"fmt"
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
 	"net/http"
 )
 
@@ -14,26 +13,47 @@ type TopUpRequest struct {
 }
 
 // GetProfile handler
-func GetProfile(c echo.Context) error {
+func (h *Handler) GetProfile(c echo.Context) error {
 	userID := c.Get("userID").(uint)
 
 	var user models.User
-	if err := database.DB.First(&user, userID).Error; err != nil {
+	if err := h.DB.First(&user, userID).Error; err != nil {
 		return echo.NewHTTPError(http.StatusNotFound, "User not found")
 	}
 
 	formattedCreatedAt := user.CreatedAt.Format("2006-01-02 15:04:05")
 
+	// Recent login activity
+	var attempts []models.LoginAttempt
+	h.DB.Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Limit(10).
+		Find(&attempts)
+
+	loginActivity := []map[string]interface{}{}
+	for _, attempt := range attempts {
+		loginActivity = append(loginActivity, map[string]interface{}{
+			"ip_address": attempt.IPAddress,
+			"user_agent": attempt.UserAgent,
+			"success":    attempt.Success,
+			"created_at": attempt.CreatedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"id":             user.ID,
 		"email":          user.Email,
 		"deposit_amount": user.DepositAmount,
+		"verified_at":    user.VerifiedAt,
+		"two_factor":     user.TOTPEnabledAt != nil,
 		"created_at":     formattedCreatedAt,
+		"profile":        formatKYCProfile(user),
+		"login_activity": loginActivity,
 	})
 }
 
 // TopUp handler
-func TopUp(c echo.Context) error {
+func (h *Handler) TopUp(c echo.Context) error {
 	userID := c.Get("userID").(uint)
 
 	var req TopUpRequest
@@ -41,27 +61,33 @@ func TopUp(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
+	tx := h.DB.Begin()
+
 	// Update saldo user
-	result := database.DB.Model(&models.User{}).
+	result := tx.Model(&models.User{}).
 		Where("id = ?", userID).
-		UpdateColumn("deposit_amount", database.DB.Raw("deposit_amount + ?", req.Amount))
+		UpdateColumn("deposit_amount", gorm.Expr("deposit_amount + ?", req.Amount))
 
 	if result.Error != nil {
+		rollbackTx(tx)
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to process top up")
 	}
 
 	// Get updated user data
 	var user models.User
-	database.DB.First(&user, userID)
-
-	// Kirim email notifikasi
-	emailService := services.NewEmailService()
-	go emailService.SendEmail(
-		user.Email,
-		"Top Up Successful",
-		fmt.Sprintf("Your deposit has been topped up with Rp%.2f. Current balance: Rp%.2f",
-			req.Amount, user.DepositAmount),
-	)
+	if err := tx.First(&user, userID).Error; err != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to process top up")
+	}
+
+	raiseEvent(tx, events.New(events.TopUpCompleted, user.ID, map[string]interface{}{
+		"amount":  req.Amount,
+		"balance": user.DepositAmount,
+	}))
+
+	if err := h.commitTx(tx); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to process top up")
+	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"message":         "Top up successful",
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"car-rental/internal/events"
+	"car-rental/internal/models"
+	"car-rental/internal/services"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+const verificationResendInterval = 2 * time.Minute
+
+// sendVerificationEmail emails a signed verification link to the user
+func (h *Handler) sendVerificationEmail(tx *gorm.DB, user models.User) error {
+	token, err := services.GenerateVerificationToken(user.ID, user.Email)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := tx.Model(&user).Update("verification_sent_at", now).Error; err != nil {
+		return err
+	}
+
+	return h.notifyUser(tx, user, "registration", "verify_email", map[string]interface{}{
+		"URL": fmt.Sprintf("%s/api/v1/verify-email?token=%s", settings.App.URL, token),
+	})
+}
+
+// VerifyEmail handler
+func (h *Handler) VerifyEmail(c echo.Context) error {
+	userID, email, err := services.ParseVerificationToken(c.QueryParam("token"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired verification link")
+	}
+
+	var user models.User
+	if err := h.DB.First(&user, userID).Error; err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired verification link")
+	}
+
+	// The link is only valid for the address it was sent to
+	if user.Email != email {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired verification link")
+	}
+
+	if user.VerifiedAt != nil {
+		return c.JSON(http.StatusOK, map[string]string{
+			"message": "Email already verified",
+		})
+	}
+
+	tx := h.DB.Begin()
+
+	if err := tx.Model(&user).Update("verified_at", time.Now()).Error; err != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify email")
+	}
+
+	raiseEvent(tx, events.New(events.EmailVerified, user.ID, map[string]interface{}{
+		"user_id": user.ID,
+	}))
+
+	if err := h.commitTx(tx); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify email")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Email verified successfully",
+	})
+}
+
+// ResendVerification handler
+func (h *Handler) ResendVerification(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+
+	var user models.User
+	if err := h.DB.First(&user, userID).Error; err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+
+	if user.VerifiedAt != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Email already verified")
+	}
+
+	// Throttle resends
+	if user.VerificationSentAt != nil && time.Since(*user.VerificationSentAt) < verificationResendInterval {
+		retryAfter := verificationResendInterval - time.Since(*user.VerificationSentAt)
+		c.Response().Header().Set("Retry-After", fmt.Sprintf("%d", int(retryAfter.Seconds())+1))
+		return echo.NewHTTPError(http.StatusTooManyRequests, "Please wait before requesting another verification email")
+	}
+
+	if err := h.sendVerificationEmail(h.DB, user); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to send verification email")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Verification email sent",
+	})
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"car-rental/internal/models"
+	"car-rental/internal/repository"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+type JoinWaitlistRequest struct {
+	RentalStart string `json:"rental_start" validate:"required"`
+	RentalEnd   string `json:"rental_end" validate:"required"`
+}
+
+// availableUnits returns the stock of a car that is not reserved by waitlist holds
+func availableUnits(waitlist repository.WaitlistRepository, car models.Car) (int, error) {
+	holds, err := waitlist.CountHolds(car.ID, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return car.StockAvailability - int(holds), nil
+}
+
+// findWaitlistHold returns the active hold offered to the user for a car, if any
+func (h *Handler) findWaitlistHold(userID, carID uint) *models.CarWaitlist {
+	entry, err := h.Waitlist.FindHold(userID, carID, time.Now())
+	if err != nil {
+		return nil
+	}
+	return entry
+}
+
+// JoinWaitlist handler
+func (h *Handler) JoinWaitlist(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+	carID, ok := paramID(c, "id")
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "Car not found")
+	}
+
+	var req JoinWaitlistRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	// Parse desired dates
+	rentalStart, err := time.Parse("2006-01-02", req.RentalStart)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid rental start date format. Use YYYY-MM-DD")
+	}
+
+	rentalEnd, err := time.Parse("2006-01-02", req.RentalEnd)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid rental end date format. Use YYYY-MM-DD")
+	}
+
+	if rentalEnd.Before(rentalStart) {
+		return echo.NewHTTPError(http.StatusBadRequest, "Rental end must be after rental start")
+	}
+
+	// Get car data
+	car, err := h.Cars.FindByID(carID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Car not found")
+	}
+
+	free, err := availableUnits(h.Waitlist, *car)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check availability")
+	}
+	if free > 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Car is available, create a rental instead")
+	}
+
+	// One open entry per user and car
+	if _, err := h.Waitlist.FindOpen(userID, car.ID); err == nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Already on the waitlist for this car")
+	}
+
+	entry := models.CarWaitlist{
+		UserID:      userID,
+		CarID:       car.ID,
+		RentalStart: rentalStart,
+		RentalEnd:   rentalEnd,
+		Status:      "waiting",
+	}
+
+	if err := h.Waitlist.Create(&entry); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to join waitlist")
+	}
+
+	// Position in queue
+	position, err := h.Waitlist.Position(entry)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to join waitlist")
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"message":  "Joined waitlist",
+		"data":     entry,
+		"position": position,
+	})
+}
+
+// LeaveWaitlist handler
+func (h *Handler) LeaveWaitlist(c echo.Context) error {
+	userID := c.Get("userID").(uint)
+	carID, ok := paramID(c, "id")
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "Waitlist entry not found")
+	}
+
+	entry, err := h.Waitlist.FindOpen(userID, carID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Waitlist entry not found")
+	}
+
+	wasOffered := entry.Status == "offered"
+	tx := h.DB.Begin()
+
+	if err := repository.NewGorm(tx).Waitlist.SetStatus(entry.ID, "cancelled"); err != nil {
+		rollbackTx(tx)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to leave waitlist")
+	}
+
+	// A released hold frees the unit for the next customer
+	if wasOffered {
+		if err := h.offerHolds(tx, entry.CarID); err != nil {
+			rollbackTx(tx)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to leave waitlist")
+		}
+	}
+
+	if err := h.commitTx(tx); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to leave waitlist")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Left waitlist",
+	})
+}
+
+// offerWaitlistHolds offers every free unit of a car to the next waiting users
+// and calls notify for each offer. Callers pass repositories bound to the
+// transaction that freed the units, so the offers commit together with it.
+func offerWaitlistHolds(repos *repository.Repositories, carID uint, notify func(entry models.CarWaitlist, car models.Car, expiresAt time.Time) error) error {
+	car, err := repos.Cars.FindByID(carID)
+	if err != nil {
+		return fmt.Errorf("find car %d for waitlist: %w", carID, err)
+	}
+
+	free, err := availableUnits(repos.Waitlist, *car)
+	if err != nil {
+		return fmt.Errorf("count waitlist holds for car %d: %w", carID, err)
+	}
+	if free <= 0 {
+		return nil
+	}
+
+	entries, err := repos.Waitlist.ListWaiting(carID, free)
+	if err != nil {
+		return fmt.Errorf("fetch waitlist for car %d: %w", carID, err)
+	}
+
+	for _, entry := range entries {
+		expiresAt := time.Now().Add(settings.Rentals.WaitlistHold)
+
+		if err := repos.Waitlist.Offer(entry.ID, expiresAt); err != nil {
+			return fmt.Errorf("offer waitlist hold %d: %w", entry.ID, err)
+		}
+		if err := notify(entry, *car, expiresAt); err != nil {
+			return fmt.Errorf("queue waitlist offer %d: %w", entry.ID, err)
+		}
+	}
+	return nil
+}
+
+// offerHolds offers the free units of a car in tx and emails each offer
+func (h *Handler) offerHolds(tx *gorm.DB, carID uint) error {
+	return offerWaitlistHolds(repository.NewGorm(tx), carID, func(entry models.CarWaitlist, car models.Car, expiresAt time.Time) error {
+		return h.notifyUser(tx, entry.User, "waitlist", "waitlist_offer", map[string]interface{}{
+			"Car":       car.Name,
+			"Start":     entry.RentalStart.Format("2006-01-02"),
+			"End":       entry.RentalEnd.Format("2006-01-02"),
+			"HoldUntil": expiresAt.Format("2006-01-02 15:04:05"),
+		})
+	})
+}
+
+// ExpireWaitlistHolds releases holds that were not converted in time
+func (h *Handler) ExpireWaitlistHolds() {
+	entries, err := h.Waitlist.ListExpiredHolds(time.Now())
+	if err != nil {
+		fmt.Printf("Error fetching expired waitlist holds: %v\n", err)
+		return
+	}
+
+	// The released unit goes to the next customer in the same transaction
+	for _, entry := range entries {
+		tx := h.DB.Begin()
+
+		if err := repository.NewGorm(tx).Waitlist.SetStatus(entry.ID, "expired"); err != nil {
+			rollbackTx(tx)
+			fmt.Printf("Error expiring waitlist hold %d: %v\n", entry.ID, err)
+			continue
+		}
+		if err := h.offerHolds(tx, entry.CarID); err != nil {
+			rollbackTx(tx)
+			fmt.Printf("Error re-offering car %d: %v\n", entry.CarID, err)
+			continue
+		}
+
+		if err := h.commitTx(tx); err != nil {
+			fmt.Printf("Error expiring waitlist hold %d: %v\n", entry.ID, err)
+		}
+	}
+}
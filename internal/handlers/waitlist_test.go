@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"car-rental/internal/models"
+	"car-rental/internal/repository"
+	"testing"
+	"time"
+)
+
+func TestOfferWaitlistHoldsFillsFreeUnits(t *testing.T) {
+	memory := repository.NewMemory()
+	repos := memory.Repositories()
+	car := memory.AddCar(models.Car{Name: "Innova", StockAvailability: 2})
+
+	// One unit is still held by a booking waiting for payment
+	holder := memory.AddUser(models.User{Email: "holder@example.com"})
+	rental := memory.AddRental(models.RentalHistory{UserID: holder.ID, CarID: car.ID, Status: "pending"})
+	memory.AddWaitlistEntry(models.CarWaitlist{UserID: holder.ID, CarID: car.ID, Status: "converted", RentalID: &rental.ID})
+
+	var queue []models.CarWaitlist
+	for _, email := range []string{"first@example.com", "second@example.com"} {
+		user := memory.AddUser(models.User{Email: email})
+		queue = append(queue, memory.AddWaitlistEntry(models.CarWaitlist{UserID: user.ID, CarID: car.ID, Status: "waiting"}))
+	}
+
+	free, err := availableUnits(repos.Waitlist, car)
+	if err != nil || free != 1 {
+		t.Fatalf("availableUnits = %d, %v, want 1", free, err)
+	}
+
+	var notified []string
+	err = offerWaitlistHolds(repos, car.ID, func(entry models.CarWaitlist, _ models.Car, expiresAt time.Time) error {
+		notified = append(notified, entry.User.Email)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(notified) != 1 || notified[0] != "first@example.com" {
+		t.Fatalf("offers sent to %v, want only the first in line", notified)
+	}
+	if first, _ := memory.WaitlistEntry(queue[0].ID); first.Status != "offered" || first.HoldExpiresAt == nil {
+		t.Errorf("first entry = %s, want an offered hold", first.Status)
+	}
+	if second, _ := memory.WaitlistEntry(queue[1].ID); second.Status != "waiting" {
+		t.Errorf("second entry = %s, want waiting", second.Status)
+	}
+
+	// Every unit is now held, nothing more is offered
+	if free, _ := availableUnits(repos.Waitlist, car); free != 0 {
+		t.Errorf("availableUnits = %d after the offer, want 0", free)
+	}
+}
@@ -0,0 +1,143 @@
+// Package health runs the readiness checks behind /readyz.
+// Each check has its own timeout and its result is cached, so frequent
+// probes do not hammer the database or external services.
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Check is one dependency probe. A failing critical check makes the
+// instance not ready, a failing non-critical one is only reported.
+type Check struct {
+	Name     string
+	Critical bool
+	Timeout  time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Result is the outcome of a check
+type Result struct {
+	Name      string    `json:"name"`
+	Status    string    `json:"status"` // ok/failed
+	Critical  bool      `json:"critical"`
+	Error     string    `json:"error,omitempty"`
+	Duration  string    `json:"duration"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Report is the combined readiness state
+type Report struct {
+	Ready  bool     `json:"ready"`
+	Checks []Result `json:"checks"`
+}
+
+type cached struct {
+	result    Result
+	expiresAt time.Time
+}
+
+// Checker runs checks and caches each result for ttl
+type Checker struct {
+	checks []Check
+	ttl    time.Duration
+
+	mu      sync.Mutex
+	results map[string]cached
+	running map[string]chan struct{}
+}
+
+func NewChecker(ttl time.Duration, checks ...Check) *Checker {
+	return &Checker{
+		checks:  checks,
+		ttl:     ttl,
+		results: map[string]cached{},
+		running: map[string]chan struct{}{},
+	}
+}
+
+// Report runs the checks concurrently, reusing results younger than ttl
+func (c *Checker) Report(ctx context.Context) Report {
+	results := make([]Result, len(c.checks))
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = c.result(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := Report{Ready: true, Checks: results}
+	for _, r := range results {
+		if r.Critical && r.Status != "ok" {
+			report.Ready = false
+		}
+	}
+	return report
+}
+
+// result returns the cached result, or runs the check once for all concurrent callers
+func (c *Checker) result(ctx context.Context, check Check) Result {
+	for {
+		c.mu.Lock()
+		if entry, ok := c.results[check.Name]; ok && time.Now().Before(entry.expiresAt) {
+			c.mu.Unlock()
+			return entry.result
+		}
+		if done, ok := c.running[check.Name]; ok {
+			c.mu.Unlock()
+			select {
+			case <-done:
+				continue
+			case <-ctx.Done():
+				return failed(check, ctx.Err(), 0)
+			}
+		}
+		done := make(chan struct{})
+		c.running[check.Name] = done
+		c.mu.Unlock()
+
+		result := run(check)
+
+		c.mu.Lock()
+		c.results[check.Name] = cached{result: result, expiresAt: time.Now().Add(c.ttl)}
+		delete(c.running, check.Name)
+		c.mu.Unlock()
+		close(done)
+		return result
+	}
+}
+
+// run executes a check under its own timeout, independent of the probe request
+func run(check Check) Result {
+	ctx, cancel := context.WithTimeout(context.Background(), check.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Run(ctx)
+	if err != nil {
+		return failed(check, err, time.Since(start))
+	}
+	return Result{
+		Name:      check.Name,
+		Status:    "ok",
+		Critical:  check.Critical,
+		Duration:  time.Since(start).Round(time.Millisecond).String(),
+		CheckedAt: time.Now(),
+	}
+}
+
+func failed(check Check, err error, took time.Duration) Result {
+	return Result{
+		Name:      check.Name,
+		Status:    "failed",
+		Critical:  check.Critical,
+		Error:     err.Error(),
+		Duration:  took.Round(time.Millisecond).String(),
+		CheckedAt: time.Now(),
+	}
+}
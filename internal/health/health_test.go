@@ -0,0 +1,144 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCheckerCachesResultsForTTL(t *testing.T) {
+	var runs int32
+	checker := NewChecker(50*time.Millisecond, Check{
+		Name:    "database",
+		Timeout: time.Second,
+		Run: func(ctx context.Context) error {
+			atomic.AddInt32(&runs, 1)
+			return nil
+		},
+	})
+
+	checker.Report(context.Background())
+	checker.Report(context.Background())
+	if got := atomic.LoadInt32(&runs); got != 1 {
+		t.Fatalf("check ran %d times within the ttl, want 1", got)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	checker.Report(context.Background())
+	if got := atomic.LoadInt32(&runs); got != 2 {
+		t.Fatalf("check ran %d times after the ttl, want 2", got)
+	}
+}
+
+func TestCheckerRunsCheckOnceForConcurrentProbes(t *testing.T) {
+	var runs int32
+	release := make(chan struct{})
+	checker := NewChecker(time.Minute, Check{
+		Name:    "database",
+		Timeout: time.Second,
+		Run: func(ctx context.Context) error {
+			atomic.AddInt32(&runs, 1)
+			<-release
+			return nil
+		},
+	})
+
+	const probes = 10
+	var wg sync.WaitGroup
+	reports := make(chan Report, probes)
+	for i := 0; i < probes; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reports <- checker.Report(context.Background())
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(reports)
+
+	if got := atomic.LoadInt32(&runs); got != 1 {
+		t.Fatalf("check ran %d times for %d concurrent probes, want 1", got, probes)
+	}
+	for report := range reports {
+		if !report.Ready {
+			t.Fatalf("probe saw %+v, want ready", report)
+		}
+	}
+}
+
+func TestCheckerWaiterGivesUpWithItsContext(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	checker := NewChecker(time.Minute, Check{
+		Name:     "database",
+		Critical: true,
+		Timeout:  time.Second,
+		Run: func(ctx context.Context) error {
+			<-release
+			return nil
+		},
+	})
+
+	go checker.Report(context.Background())
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	report := checker.Report(ctx)
+	if report.Ready || report.Checks[0].Status != "failed" {
+		t.Fatalf("waiting probe got %+v, want a failed check once its context ended", report)
+	}
+}
+
+func TestCheckerReadiness(t *testing.T) {
+	down := errors.New("down")
+	tests := []struct {
+		name     string
+		critical bool
+		err      error
+		ready    bool
+	}{
+		{"critical ok", true, nil, true},
+		{"critical failed", true, down, false},
+		{"optional failed", false, down, true},
+	}
+
+	for _, tt := range tests {
+		err := tt.err
+		checker := NewChecker(time.Minute, Check{
+			Name:     "dependency",
+			Critical: tt.critical,
+			Timeout:  time.Second,
+			Run:      func(ctx context.Context) error { return err },
+		})
+		report := checker.Report(context.Background())
+		if report.Ready != tt.ready {
+			t.Errorf("%s: ready = %v, want %v", tt.name, report.Ready, tt.ready)
+		}
+		if tt.err != nil && report.Checks[0].Error != tt.err.Error() {
+			t.Errorf("%s: error = %q, want %q", tt.name, report.Checks[0].Error, tt.err.Error())
+		}
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	checker := NewChecker(time.Minute, Check{
+		Name:     "slow",
+		Critical: true,
+		Timeout:  10 * time.Millisecond,
+		Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+
+	report := checker.Report(context.Background())
+	if report.Ready || report.Checks[0].Error != context.DeadlineExceeded.Error() {
+		t.Fatalf("report = %+v, want the slow check to time out", report)
+	}
+}
@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Middleware records the count and latency of every request.
+// Routes are labelled by their pattern, e.g. /api/v1/cars/:id, so ids do not
+// create new series, and unmatched paths share one label.
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+
+			// The error handler has not written the response yet, take the status from the error
+			status := c.Response().Status
+			if err != nil {
+				var he *echo.HTTPError
+				if errors.As(err, &he) {
+					status = he.Code
+				} else {
+					status = http.StatusInternalServerError
+				}
+			}
+
+			route := c.Path()
+			if route == "" || status == http.StatusNotFound && (route == "/*" || route == "*") {
+				route = "unmatched"
+			}
+
+			labels := []string{c.Request().Method, route, strconv.Itoa(status)}
+			httpRequests.WithLabelValues(labels...).Inc()
+			httpDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+			return err
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddlewareLabelsRoutes(t *testing.T) {
+	e := echo.New()
+	e.Use(Middleware())
+	e.GET("/test/cars/:id", func(c echo.Context) error {
+		if c.Param("id") == "404" {
+			return echo.NewHTTPError(http.StatusNotFound, "Car not found")
+		}
+		return c.NoContent(http.StatusOK)
+	})
+
+	tests := []struct {
+		path   string
+		route  string
+		status string
+	}{
+		{"/test/cars/1", "/test/cars/:id", "200"},
+		{"/test/cars/2", "/test/cars/:id", "200"},
+		{"/test/cars/404", "/test/cars/:id", "404"},
+		{"/test/no-such-page", "unmatched", "404"},
+		{"/test/other/random-id", "unmatched", "404"},
+	}
+
+	before := map[[2]string]float64{}
+	for _, tt := range tests {
+		key := [2]string{tt.route, tt.status}
+		before[key] = testutil.ToFloat64(httpRequests.WithLabelValues(http.MethodGet, tt.route, tt.status))
+	}
+
+	for _, tt := range tests {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+	}
+
+	want := map[[2]string]float64{}
+	for _, tt := range tests {
+		want[[2]string{tt.route, tt.status}]++
+	}
+	for key, count := range want {
+		got := testutil.ToFloat64(httpRequests.WithLabelValues(http.MethodGet, key[0], key[1])) - before[key]
+		if got != count {
+			t.Errorf("route %s status %s counted %v times, want %v", key[0], key[1], got, count)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Package metrics exposes Prometheus metrics at /metrics.
+// HTTP and database metrics are collected by middleware and a GORM plugin,
+// delivery and business metrics are incremented where the work happens.
+package metrics
+
+import (
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+)
+
+const namespace = "car_rental"
+
+// Registry holds every metric of the service, plus Go runtime and process metrics
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+var (
+	httpRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route, method and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	dbQueryDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "GORM query latency by operation and table.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "table"})
+
+	dbQueryErrors = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_query_errors_total",
+		Help:      "GORM queries that failed, not counting record not found.",
+	}, []string{"operation", "table"})
+
+	// NotificationDeliveries counts outbox sends per channel, result is sent, failed (retried) or dead
+	NotificationDeliveries = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notification_deliveries_total",
+		Help:      "Outbox notification send attempts by channel and result.",
+	}, []string{"channel", "result"})
+
+	// WebhookDeliveries counts outgoing webhook attempts, result is delivered, failed (retried) or dead
+	WebhookDeliveries = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Outgoing webhook delivery attempts by event type and result.",
+	}, []string{"event_type", "result"})
+
+	WebhookDuration = factory.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "webhook_delivery_duration_seconds",
+		Help:      "Time taken by integrator endpoints to answer a webhook.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10},
+	})
+
+	RentalsCreated = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rentals_created_total",
+		Help:      "Rentals booked, before payment.",
+	})
+
+	RentalsActivated = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rentals_activated_total",
+		Help:      "Rentals activated by a paid invoice.",
+	})
+
+	RentalsReturned = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rentals_returned_total",
+		Help:      "Rentals completed by returning the car.",
+	})
+
+	Revenue = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "revenue_total",
+		Help:      "Paid rental revenue by car category.",
+	}, []string{"category"})
+
+	TopUps = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "wallet_topups_total",
+		Help:      "Completed wallet top ups.",
+	})
+
+	TopUpAmount = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "wallet_topup_amount_total",
+		Help:      "Amount added to wallets by top ups.",
+	})
+)
+
+// RegisterDBStats exports the connection pool statistics of db
+func RegisterDBStats(db *sql.DB) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, "postgres"))
+}
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
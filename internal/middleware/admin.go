@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"car-rental/internal/models"
+	"car-rental/pkg/database"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+// Admin only lets users with the admin role through
+func Admin(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		userID := c.Get("userID").(uint)
+
+		var user models.User
+		if err := database.DB.Select("id", "role").First(&user, userID).Error; err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "User not found")
+		}
+
+		if user.Role != "admin" {
+			return echo.NewHTTPError(http.StatusForbidden, "Admin access required")
+		}
+
+		return next(c)
+	}
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"car-rental/internal/models"
+	"car-rental/internal/services"
+	"car-rental/pkg/database"
+	"crypto/subtle"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// APIKey authenticates partner requests by the X-API-Key header and requires the given scope
+func APIKey(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			rawKey := c.Request().Header.Get("X-API-Key")
+			if rawKey == "" {
+				return echo.NewHTTPError(http.StatusUnauthorized, "API key is required")
+			}
+
+			// Keys look like rk_<prefix>_<secret>
+			parts := strings.Split(rawKey, "_")
+			if len(parts) != 3 || parts[0] != "rk" {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid API key")
+			}
+			prefix := parts[0] + "_" + parts[1]
+
+			var key models.APIKey
+			if err := database.DB.Where("prefix = ?", prefix).First(&key).Error; err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid API key")
+			}
+
+			if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(services.HashToken(rawKey))) != 1 {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid API key")
+			}
+
+			if key.RevokedAt != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "API key has been revoked")
+			}
+			if key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt) {
+				return echo.NewHTTPError(http.StatusUnauthorized, "API key has expired")
+			}
+
+			if !key.HasScope(scope) {
+				return echo.NewHTTPError(http.StatusForbidden, "API key is missing scope "+scope)
+			}
+
+			// Track usage without writing on every request
+			if key.LastUsedAt == nil || time.Since(*key.LastUsedAt) > time.Minute {
+				database.DB.Model(&key).Update("last_used_at", time.Now())
+			}
+
+			c.Set("partnerID", key.PartnerID)
+			c.Set("apiKeyID", key.ID)
+			return next(c)
+		}
+	}
+}
@@ -1,11 +1,14 @@
 package middleware
 
 import (
+	"car-rental/internal/models"
+	"car-rental/internal/services"
+	"car-rental/pkg/database"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 	"net/http"
-	"os"
 	"strings"
+	"time"
 )
 
 func JWT(next echo.HandlerFunc) echo.HandlerFunc {
@@ -24,20 +27,62 @@ func JWT(next echo.HandlerFunc) echo.HandlerFunc {
 
 		// Validate token
 		tokenString := parts[1]
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte(os.Getenv("JWT_SECRET")), nil
-		})
+		token, err := services.ParseJWT(tokenString)
 
 		if err != nil || !token.Valid {
 			return echo.NewHTTPError(http.StatusUnauthorized, "Invalid token")
 		}
 
 		// Get claims
-		if claims, ok := token.Claims.(jwt.MapClaims); ok {
-			c.Set("userID", uint(claims["user_id"].(float64)))
-			return next(c)
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Invalid token claims")
 		}
 
-		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid token claims")
+		jti, ok := claims["jti"].(string)
+		if !ok || jti == "" {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Invalid token claims")
+		}
+
+		// Check jti denylist
+		var revoked int64
+		if err := database.DB.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&revoked).Error; err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to validate token")
+		}
+		if revoked > 0 {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Token has been revoked")
+		}
+
+		exp, err := claims.GetExpirationTime()
+		if err != nil || exp == nil {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Invalid token claims")
+		}
+
+		userID, okUser := claims["user_id"].(float64)
+		sessionID, okSession := claims["sid"].(float64)
+		if !okUser || !okSession {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Invalid token claims")
+		}
+
+		// Check the session is still active
+		var session models.UserSession
+		if err := database.DB.First(&session, uint(sessionID)).Error; err != nil ||
+			session.UserID != uint(userID) || session.RevokedAt != nil {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Session has been revoked")
+		}
+
+		// Track activity without writing on every request
+		if time.Since(session.LastSeenAt) > time.Minute {
+			database.DB.Model(&session).Updates(map[string]interface{}{
+				"last_seen_at": time.Now(),
+				"ip_address":   c.RealIP(),
+			})
+		}
+
+		c.Set("userID", uint(userID))
+		c.Set("sessionID", session.ID)
+		c.Set("jti", jti)
+		c.Set("tokenExpiresAt", exp.Time)
+		return next(c)
 	}
 }
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strings"
+)
+
+// MetricsToken protects /metrics with a static bearer token, an empty token leaves it open
+func MetricsToken(token string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if token == "" {
+				return next(c)
+			}
+
+			given := strings.TrimPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid metrics token")
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"car-rental/internal/models"
+	"car-rental/pkg/database"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+// VerifiedEmail blocks users who have not verified their email address
+func VerifiedEmail(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		userID := c.Get("userID").(uint)
+
+		var user models.User
+		if err := database.DB.Select("id", "verified_at").First(&user, userID).Error; err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "User not found")
+		}
+
+		if user.VerifiedAt == nil {
+			return echo.NewHTTPError(http.StatusForbidden, "Email is not verified")
+		}
+
+		return next(c)
+	}
+}
@@ -0,0 +1,635 @@
+-- Adopts a database whose schema was created by hand before versioned
+-- migrations existed, so table, constraint and index names may differ from
+-- 0001-0008. Every statement is idempotent: missing tables are created as in
+-- 0001-0008, missing columns are added (NOT NULL columns get a default so
+-- existing rows stay valid) and missing indexes are built. The users email key
+-- and the foreign keys of the tables that predate migrations are added only
+-- when no equivalent exists under another name.
+-- Column types of existing columns are left alone.
+
+-- 0001_create_users
+CREATE TABLE IF NOT EXISTS users (
+    id                   BIGSERIAL PRIMARY KEY,
+    email                TEXT NOT NULL,
+    password             TEXT NOT NULL,
+    deposit_amount       NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    role                 TEXT NOT NULL DEFAULT 'customer',
+    full_name            TEXT NOT NULL DEFAULT '',
+    phone                TEXT NOT NULL DEFAULT '',
+    language             TEXT NOT NULL DEFAULT 'en',
+    webhook_url          TEXT NOT NULL DEFAULT '',
+    date_of_birth        TIMESTAMPTZ,
+    address              TEXT NOT NULL DEFAULT '',
+    license_number       TEXT NOT NULL DEFAULT '',
+    license_expiry       TIMESTAMPTZ,
+    kyc_status           TEXT NOT NULL DEFAULT 'none',
+    kyc_reviewed_at      TIMESTAMPTZ,
+    kyc_reject_reason    TEXT NOT NULL DEFAULT '',
+    verified_at          TIMESTAMPTZ,
+    verification_sent_at TIMESTAMPTZ,
+    failed_login_count   INTEGER NOT NULL DEFAULT 0,
+    locked_until         TIMESTAMPTZ,
+    unlock_token_hash    TEXT,
+    totp_secret          TEXT,
+    totp_enabled_at      TIMESTAMPTZ,
+    totp_last_step       BIGINT NOT NULL DEFAULT 0,
+    created_at           TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at           TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT users_email_key UNIQUE (email),
+    CONSTRAINT users_deposit_amount_check CHECK (deposit_amount >= 0)
+);
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS email                TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS password             TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS deposit_amount       NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS role                 TEXT NOT NULL DEFAULT 'customer',
+    ADD COLUMN IF NOT EXISTS full_name            TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS phone                TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS language             TEXT NOT NULL DEFAULT 'en',
+    ADD COLUMN IF NOT EXISTS webhook_url          TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS date_of_birth        TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS address              TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS license_number       TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS license_expiry       TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS kyc_status           TEXT NOT NULL DEFAULT 'none',
+    ADD COLUMN IF NOT EXISTS kyc_reviewed_at      TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS kyc_reject_reason    TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS verified_at          TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS verification_sent_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS failed_login_count   INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS locked_until         TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS unlock_token_hash    TEXT,
+    ADD COLUMN IF NOT EXISTS totp_secret          TEXT,
+    ADD COLUMN IF NOT EXISTS totp_enabled_at      TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS totp_last_step       BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS created_at           TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS updated_at           TIMESTAMPTZ NOT NULL DEFAULT now();
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'users_deposit_amount_check') THEN
+        ALTER TABLE users ADD CONSTRAINT users_deposit_amount_check CHECK (deposit_amount >= 0);
+    END IF;
+END
+$$;
+
+-- A hand-made users table may already keep emails unique under any name
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_index i
+        JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = i.indkey[0]
+        WHERE i.indrelid = 'users'::regclass
+          AND i.indisunique
+          AND i.indnatts = 1
+          AND i.indpred IS NULL
+          AND a.attname = 'email'
+    ) THEN
+        ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
+    END IF;
+END
+$$;
+
+CREATE INDEX IF NOT EXISTS idx_users_kyc_status ON users (kyc_status);
+CREATE INDEX IF NOT EXISTS idx_users_unlock_token_hash ON users (unlock_token_hash) WHERE unlock_token_hash IS NOT NULL;
+
+-- Accounts from before email verification never got a link, as in 0010
+UPDATE users
+SET verified_at = created_at
+WHERE verified_at IS NULL
+  AND verification_sent_at IS NULL;
+
+-- 0002_create_cars
+CREATE TABLE IF NOT EXISTS cars (
+    id                 BIGSERIAL PRIMARY KEY,
+    name               TEXT NOT NULL,
+    stock_availability INTEGER NOT NULL DEFAULT 0,
+    rental_costs       NUMERIC(14, 2) NOT NULL,
+    category           TEXT NOT NULL,
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at         TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE cars
+    ADD COLUMN IF NOT EXISTS name               TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS stock_availability INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS rental_costs       NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS category           TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS created_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS updated_at         TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS idx_cars_category ON cars (category);
+
+-- 0003_create_partners
+CREATE TABLE IF NOT EXISTS partners (
+    id            BIGSERIAL PRIMARY KEY,
+    name          TEXT NOT NULL,
+    contact_email TEXT NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE partners
+    ADD COLUMN IF NOT EXISTS name          TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS contact_email TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS updated_at    TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id           BIGSERIAL PRIMARY KEY,
+    partner_id   BIGINT NOT NULL REFERENCES partners (id) ON DELETE CASCADE,
+    name         TEXT NOT NULL,
+    prefix       TEXT NOT NULL,
+    key_hash     TEXT NOT NULL,
+    scopes       TEXT NOT NULL DEFAULT '',
+    expires_at   TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT api_keys_prefix_key UNIQUE (prefix)
+);
+
+ALTER TABLE api_keys
+    ADD COLUMN IF NOT EXISTS name         TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS prefix       TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS key_hash     TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS scopes       TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS expires_at   TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS revoked_at   TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS created_at   TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE UNIQUE INDEX IF NOT EXISTS api_keys_prefix_key ON api_keys (prefix);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_partner_id ON api_keys (partner_id);
+
+-- 0004_create_rentals_and_payments
+CREATE TABLE IF NOT EXISTS rental_history (
+    id                  BIGSERIAL PRIMARY KEY,
+    user_id             BIGINT NOT NULL REFERENCES users (id),
+    car_id              BIGINT NOT NULL REFERENCES cars (id),
+    rental_start        TIMESTAMPTZ NOT NULL,
+    rental_end          TIMESTAMPTZ NOT NULL,
+    total_cost          NUMERIC(14, 2) NOT NULL,
+    status              TEXT NOT NULL,
+    partner_id          BIGINT REFERENCES partners (id) ON DELETE SET NULL,
+    pickup_reminded_at  TIMESTAMPTZ,
+    return_reminded_at  TIMESTAMPTZ,
+    overdue_notified_at TIMESTAMPTZ,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at          TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE rental_history
+    ADD COLUMN IF NOT EXISTS rental_start        TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS rental_end          TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS total_cost          NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS status              TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS partner_id          BIGINT REFERENCES partners (id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS pickup_reminded_at  TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS return_reminded_at  TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS overdue_notified_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS created_at          TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS updated_at          TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS idx_rental_history_user_id ON rental_history (user_id);
+CREATE INDEX IF NOT EXISTS idx_rental_history_car_id ON rental_history (car_id);
+CREATE INDEX IF NOT EXISTS idx_rental_history_partner_id ON rental_history (partner_id) WHERE partner_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_rental_history_status_start ON rental_history (status, rental_start);
+CREATE INDEX IF NOT EXISTS idx_rental_history_status_end ON rental_history (status, rental_end);
+CREATE TABLE IF NOT EXISTS payments (
+    id          BIGSERIAL PRIMARY KEY,
+    rental_id   BIGINT NOT NULL REFERENCES rental_history (id) ON DELETE CASCADE,
+    invoice_id  TEXT NOT NULL,
+    amount      NUMERIC(14, 2) NOT NULL,
+    status      TEXT NOT NULL,
+    payment_url TEXT NOT NULL,
+    external_id TEXT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT payments_external_id_key UNIQUE (external_id)
+);
+
+ALTER TABLE payments
+    ADD COLUMN IF NOT EXISTS invoice_id  TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS amount      NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS status      TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS payment_url TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS external_id TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS updated_at  TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE UNIQUE INDEX IF NOT EXISTS payments_external_id_key ON payments (external_id);
+
+CREATE INDEX IF NOT EXISTS idx_payments_rental_id ON payments (rental_id);
+CREATE INDEX IF NOT EXISTS idx_payments_invoice_id ON payments (invoice_id);
+
+-- 0005_create_auth_tables
+CREATE TABLE IF NOT EXISTS user_sessions (
+    id           BIGSERIAL PRIMARY KEY,
+    user_id      BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    device       TEXT NOT NULL,
+    user_agent   TEXT NOT NULL DEFAULT '',
+    ip_address   TEXT NOT NULL,
+    last_seen_at TIMESTAMPTZ NOT NULL,
+    revoked_at   TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE user_sessions
+    ADD COLUMN IF NOT EXISTS device       TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS user_agent   TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS ip_address   TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS revoked_at   TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS created_at   TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS idx_user_sessions_user_id ON user_sessions (user_id);
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id             BIGSERIAL PRIMARY KEY,
+    user_id        BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    session_id     BIGINT NOT NULL REFERENCES user_sessions (id) ON DELETE CASCADE,
+    token_hash     TEXT NOT NULL,
+    expires_at     TIMESTAMPTZ NOT NULL,
+    revoked_at     TIMESTAMPTZ,
+    replaced_by_id BIGINT REFERENCES refresh_tokens (id) ON DELETE SET NULL,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT refresh_tokens_token_hash_key UNIQUE (token_hash)
+);
+
+ALTER TABLE refresh_tokens
+    ADD COLUMN IF NOT EXISTS token_hash     TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS expires_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS revoked_at     TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS replaced_by_id BIGINT REFERENCES refresh_tokens (id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS created_at     TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE UNIQUE INDEX IF NOT EXISTS refresh_tokens_token_hash_key ON refresh_tokens (token_hash);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens (user_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_session_id ON refresh_tokens (session_id);
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    id         BIGSERIAL PRIMARY KEY,
+    jti        TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT revoked_tokens_jti_key UNIQUE (jti)
+);
+
+ALTER TABLE revoked_tokens
+    ADD COLUMN IF NOT EXISTS jti        TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE UNIQUE INDEX IF NOT EXISTS revoked_tokens_jti_key ON revoked_tokens (jti);
+
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires_at ON revoked_tokens (expires_at);
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT password_reset_tokens_token_hash_key UNIQUE (token_hash)
+);
+
+ALTER TABLE password_reset_tokens
+    ADD COLUMN IF NOT EXISTS token_hash TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS used_at    TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE UNIQUE INDEX IF NOT EXISTS password_reset_tokens_token_hash_key ON password_reset_tokens (token_hash);
+
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user_id ON password_reset_tokens (user_id);
+CREATE TABLE IF NOT EXISTS login_attempts (
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    BIGINT REFERENCES users (id) ON DELETE SET NULL,
+    email      TEXT NOT NULL,
+    ip_address TEXT NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    success    BOOLEAN NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE login_attempts
+    ADD COLUMN IF NOT EXISTS user_id    BIGINT REFERENCES users (id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS email      TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS ip_address TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS user_agent TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS success    BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS idx_login_attempts_user_id ON login_attempts (user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_email ON login_attempts (email, created_at);
+CREATE TABLE IF NOT EXISTS rate_limit_hits (
+    id         BIGSERIAL PRIMARY KEY,
+    key        TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE rate_limit_hits
+    ADD COLUMN IF NOT EXISTS key        TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS idx_rate_limit_hits_key ON rate_limit_hits (key, created_at);
+CREATE INDEX IF NOT EXISTS idx_rate_limit_hits_created_at ON rate_limit_hits (created_at);
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code_hash  TEXT NOT NULL,
+    used_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE recovery_codes
+    ADD COLUMN IF NOT EXISTS code_hash  TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS used_at    TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON recovery_codes (user_id);
+CREATE TABLE IF NOT EXISTS user_identities (
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    issuer     TEXT NOT NULL,
+    subject    TEXT NOT NULL,
+    email      TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE user_identities
+    ADD COLUMN IF NOT EXISTS issuer     TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS subject    TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS email      TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_identity_subject ON user_identities (issuer, subject);
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities (user_id);
+CREATE TABLE IF NOT EXISTS oidc_states (
+    id            BIGSERIAL PRIMARY KEY,
+    state         TEXT NOT NULL,
+    nonce         TEXT NOT NULL,
+    code_verifier TEXT NOT NULL,
+    expires_at    TIMESTAMPTZ NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT oidc_states_state_key UNIQUE (state)
+);
+
+ALTER TABLE oidc_states
+    ADD COLUMN IF NOT EXISTS state         TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS nonce         TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS code_verifier TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS expires_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS created_at    TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE UNIQUE INDEX IF NOT EXISTS oidc_states_state_key ON oidc_states (state);
+
+CREATE INDEX IF NOT EXISTS idx_oidc_states_expires_at ON oidc_states (expires_at);
+
+-- 0006_create_kyc_and_waitlist
+CREATE TABLE IF NOT EXISTS kyc_documents (
+    id           BIGSERIAL PRIMARY KEY,
+    user_id      BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    type         TEXT NOT NULL,
+    file_path    TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE kyc_documents
+    ADD COLUMN IF NOT EXISTS type         TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS file_path    TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS content_type TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS created_at   TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS idx_kyc_documents_user_id ON kyc_documents (user_id);
+CREATE TABLE IF NOT EXISTS car_waitlist (
+    id              BIGSERIAL PRIMARY KEY,
+    user_id         BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    car_id          BIGINT NOT NULL REFERENCES cars (id) ON DELETE CASCADE,
+    rental_start    TIMESTAMPTZ NOT NULL,
+    rental_end      TIMESTAMPTZ NOT NULL,
+    status          TEXT NOT NULL,
+    hold_expires_at TIMESTAMPTZ,
+    rental_id       BIGINT REFERENCES rental_history (id) ON DELETE SET NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE car_waitlist
+    ADD COLUMN IF NOT EXISTS rental_start    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS rental_end      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS status          TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS hold_expires_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS rental_id       BIGINT REFERENCES rental_history (id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS updated_at      TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS idx_car_waitlist_car_status ON car_waitlist (car_id, status, created_at);
+CREATE INDEX IF NOT EXISTS idx_car_waitlist_user_id ON car_waitlist (user_id);
+CREATE INDEX IF NOT EXISTS idx_car_waitlist_hold_expires_at ON car_waitlist (hold_expires_at) WHERE status = 'offered';
+
+-- 0007_create_notifications
+CREATE TABLE IF NOT EXISTS user_notifications (
+    id           BIGSERIAL PRIMARY KEY,
+    user_id      BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    type         TEXT NOT NULL,
+    email_status TEXT NOT NULL,
+    subject      TEXT NOT NULL,
+    message      TEXT NOT NULL,
+    read_at      TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE user_notifications
+    ADD COLUMN IF NOT EXISTS type         TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS email_status TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS subject      TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS message      TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS read_at      TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS created_at   TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS idx_user_notifications_user_id ON user_notifications (user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_user_notifications_unread ON user_notifications (user_id) WHERE read_at IS NULL;
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    type       TEXT NOT NULL,
+    channels   TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE notification_preferences
+    ADD COLUMN IF NOT EXISTS type       TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS channels   TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notification_preferences_user_type ON notification_preferences (user_id, type);
+CREATE TABLE IF NOT EXISTS notification_outbox (
+    id              BIGSERIAL PRIMARY KEY,
+    notification_id BIGINT REFERENCES user_notifications (id) ON DELETE SET NULL,
+    channel         TEXT NOT NULL DEFAULT 'email',
+    type            TEXT NOT NULL DEFAULT '',
+    recipient       TEXT NOT NULL,
+    subject         TEXT NOT NULL,
+    body            TEXT NOT NULL,
+    html_body       TEXT NOT NULL DEFAULT '',
+    status          TEXT NOT NULL,
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    max_attempts    INTEGER NOT NULL,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    locked_until    TIMESTAMPTZ,
+    last_error      TEXT NOT NULL DEFAULT '',
+    sent_at         TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE notification_outbox
+    ADD COLUMN IF NOT EXISTS notification_id BIGINT REFERENCES user_notifications (id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS channel         TEXT NOT NULL DEFAULT 'email',
+    ADD COLUMN IF NOT EXISTS type            TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS recipient       TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS subject         TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS body            TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS html_body       TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS status          TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS attempts        INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS max_attempts    INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS locked_until    TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS last_error      TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS sent_at         TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS updated_at      TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS idx_notification_outbox_status ON notification_outbox (status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_notification_outbox_notification_id ON notification_outbox (notification_id);
+
+-- The tables that predate migrations may lack their foreign keys. They are
+-- added NOT VALID, so rows written before adoption are not rechecked.
+DO $$
+DECLARE
+    fk RECORD;
+BEGIN
+    FOR fk IN
+        SELECT * FROM (VALUES
+            ('rental_history', 'user_id', 'users', ''),
+            ('rental_history', 'car_id', 'cars', ''),
+            ('payments', 'rental_id', 'rental_history', 'ON DELETE CASCADE'),
+            ('user_notifications', 'user_id', 'users', 'ON DELETE CASCADE')
+        ) AS t (tbl, col, ref, on_delete)
+    LOOP
+        IF NOT EXISTS (
+            SELECT 1
+            FROM pg_constraint c
+            JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = c.conkey[1]
+            WHERE c.contype = 'f'
+              AND c.conrelid = fk.tbl::regclass
+              AND array_length(c.conkey, 1) = 1
+              AND a.attname = fk.col
+        ) THEN
+            EXECUTE format('ALTER TABLE %I ADD FOREIGN KEY (%I) REFERENCES %I (id) %s NOT VALID',
+                fk.tbl, fk.col, fk.ref, fk.on_delete);
+        END IF;
+    END LOOP;
+END
+$$;
+
+-- 0008_create_events_and_webhooks
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id          BIGSERIAL PRIMARY KEY,
+    event_type  TEXT NOT NULL,
+    user_id     BIGINT REFERENCES users (id) ON DELETE SET NULL,
+    data        TEXT NOT NULL DEFAULT '',
+    occurred_at TIMESTAMPTZ NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE audit_logs
+    ADD COLUMN IF NOT EXISTS event_type  TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS user_id     BIGINT REFERENCES users (id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS data        TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS occurred_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS created_at  TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_event_type ON audit_logs (event_type);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_user_id ON audit_logs (user_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_occurred_at ON audit_logs (occurred_at);
+CREATE TABLE IF NOT EXISTS event_counts (
+    day        DATE NOT NULL,
+    event_type TEXT NOT NULL,
+    count      BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, event_type)
+);
+
+ALTER TABLE event_counts
+    ADD COLUMN IF NOT EXISTS day        DATE NOT NULL DEFAULT CURRENT_DATE,
+    ADD COLUMN IF NOT EXISTS event_type TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS count      BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS webhook_endpoints (
+    id          BIGSERIAL PRIMARY KEY,
+    url         TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    event_types TEXT NOT NULL,
+    secret      TEXT NOT NULL,
+    active      BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE webhook_endpoints
+    ADD COLUMN IF NOT EXISTS url         TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS event_types TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS secret      TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS active      BOOLEAN NOT NULL DEFAULT TRUE,
+    ADD COLUMN IF NOT EXISTS created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS updated_at  TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id              BIGSERIAL PRIMARY KEY,
+    endpoint_id     BIGINT NOT NULL REFERENCES webhook_endpoints (id) ON DELETE CASCADE,
+    event_id        TEXT NOT NULL,
+    event_type      TEXT NOT NULL,
+    payload         TEXT NOT NULL,
+    status          TEXT NOT NULL,
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    max_attempts    INTEGER NOT NULL,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    locked_until    TIMESTAMPTZ,
+    response_code   INTEGER,
+    response_body   TEXT NOT NULL DEFAULT '',
+    last_error      TEXT NOT NULL DEFAULT '',
+    delivered_at    TIMESTAMPTZ,
+    redelivery_of   BIGINT REFERENCES webhook_deliveries (id) ON DELETE SET NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE webhook_deliveries
+    ADD COLUMN IF NOT EXISTS event_id        TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS event_type      TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS payload         TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS status          TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS attempts        INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS max_attempts    INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS locked_until    TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS response_code   INTEGER,
+    ADD COLUMN IF NOT EXISTS response_body   TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS last_error      TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS delivered_at    TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS redelivery_of   BIGINT REFERENCES webhook_deliveries (id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS updated_at      TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_endpoint_id ON webhook_deliveries (endpoint_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_event_id ON webhook_deliveries (event_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_status ON webhook_deliveries (status, next_attempt_at);
@@ -0,0 +1,366 @@
+// Package migrations applies the versioned SQL schema embedded in the binary.
+// Files in sql/ are named <version>_<name>.up.sql and <version>_<name>.down.sql,
+// applied versions are recorded in the schema_migrations table.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// baselineScript brings a hand-made schema up to migration baselineVersion
+//
+//go:embed baseline.sql
+var baselineScript string
+
+// lockID keeps two instances from migrating the same database at once
+const lockID = 7265636172
+
+// baselineVersion is the last migration covered by baseline.sql
+const baselineVersion = 8
+
+// ErrUnversioned means the database has tables but no migration history,
+// its schema was made by hand and has to be adopted with Baseline first
+var ErrUnversioned = errors.New("database has tables but no migration history, run `migrate baseline` first")
+
+// Migration is one schema version with its up and down scripts
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status reports whether a migration has been applied
+type Status struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+}
+
+// All returns the embedded migrations ordered by version
+func All() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		fileName := entry.Name()
+		base, direction, ok := splitName(fileName)
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name %q", fileName)
+		}
+
+		versionPart, name, _ := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(versionPart, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %q", fileName)
+		}
+
+		body, err := files.ReadFile("sql/" + fileName)
+		if err != nil {
+			return nil, err
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both up and down files", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// splitName turns 0001_create_users.up.sql into 0001_create_users and up
+func splitName(fileName string) (string, string, bool) {
+	for _, direction := range []string{"up", "down"} {
+		if base, ok := strings.CutSuffix(fileName, "."+direction+".sql"); ok {
+			return base, direction, true
+		}
+	}
+	return "", "", false
+}
+
+// Up applies every pending migration and returns the ones it applied
+func Up(db *sql.DB) ([]Migration, error) {
+	migrations, err := All()
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	err = withLock(db, func(conn *sql.Conn) error {
+		done, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		if len(done) == 0 {
+			legacy, err := hasLegacyTables(conn)
+			if err != nil {
+				return err
+			}
+			if legacy {
+				return ErrUnversioned
+			}
+		}
+
+		for _, m := range migrations {
+			if _, ok := done[m.Version]; ok {
+				continue
+			}
+			if err := run(conn, m.Up, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.Version, m.Name); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+			}
+			applied = append(applied, m)
+		}
+		return nil
+	})
+
+	return applied, err
+}
+
+// Baseline adopts a database whose schema was made by hand: it runs baseline.sql to add
+// whatever tables, columns and indexes are missing, then records migrations up to
+// baselineVersion as applied so Up continues from there
+func Baseline(db *sql.DB) ([]Migration, error) {
+	migrations, err := All()
+	if err != nil {
+		return nil, err
+	}
+
+	var adopted []Migration
+	err = withLock(db, func(conn *sql.Conn) error {
+		done, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		if len(done) > 0 {
+			return errors.New("database already has migration history, use `migrate up`")
+		}
+
+		tx, err := conn.BeginTx(context.Background(), nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(context.Background(), baselineScript); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("baseline: %w", err)
+		}
+		for _, m := range migrations {
+			if m.Version > baselineVersion {
+				break
+			}
+			if _, err := tx.ExecContext(context.Background(), "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.Version, m.Name); err != nil {
+				tx.Rollback()
+				return err
+			}
+			adopted = append(adopted, m)
+		}
+		if err := tx.Commit(); err != nil {
+			adopted = nil
+			return err
+		}
+		return nil
+	})
+
+	return adopted, err
+}
+
+// Down rolls back the latest applied migrations, steps at a time
+func Down(db *sql.DB, steps int) ([]Migration, error) {
+	migrations, err := All()
+	if err != nil {
+		return nil, err
+	}
+
+	var reverted []Migration
+	err = withLock(db, func(conn *sql.Conn) error {
+		done, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			m := migrations[i]
+			if _, ok := done[m.Version]; !ok {
+				continue
+			}
+			if err := run(conn, m.Down, "DELETE FROM schema_migrations WHERE version = $1", m.Version); err != nil {
+				return fmt.Errorf("rollback %d_%s: %w", m.Version, m.Name, err)
+			}
+			reverted = append(reverted, m)
+		}
+		return nil
+	})
+
+	return reverted, err
+}
+
+// Statuses lists every embedded migration with its applied time
+func Statuses(db *sql.DB) ([]Status, error) {
+	migrations, err := All()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := ensureTable(conn); err != nil {
+		return nil, err
+	}
+	done, err := appliedVersions(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(migrations))
+	for _, m := range migrations {
+		s := Status{Version: m.Version, Name: m.Name}
+		if appliedAt, ok := done[m.Version]; ok {
+			s.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// withLock runs fn on one connection holding the migration advisory lock
+func withLock(db *sql.DB, fn func(conn *sql.Conn) error) error {
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_lock($1)", lockID); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockID)
+
+	if err := ensureTable(conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func ensureTable(conn *sql.Conn) error {
+	_, err := conn.ExecContext(context.Background(), `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    BIGINT PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	return err
+}
+
+func appliedVersions(conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(context.Background(), "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	done := map[int64]time.Time{}
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		done[version] = appliedAt
+	}
+	return done, rows.Err()
+}
+
+// hasLegacyTables reports whether the users table exists, which every
+// database from before migrations has
+func hasLegacyTables(conn *sql.Conn) (bool, error) {
+	var exists bool
+	err := conn.QueryRowContext(context.Background(), "SELECT to_regclass('users') IS NOT NULL").Scan(&exists)
+	return exists, err
+}
+
+// run executes a script and its bookkeeping statement in one transaction
+func run(conn *sql.Conn, script, record string, args ...interface{}) error {
+	tx, err := conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(context.Background(), script); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.ExecContext(context.Background(), record, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Pending counts embedded migrations not yet applied, without changing the database
+func Pending(ctx context.Context, db *sql.DB) (int, error) {
+	migrations, err := All()
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	done := map[int64]bool{}
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			return 0, err
+		}
+		done[version] = true
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for _, m := range migrations {
+		if !done[m.Version] {
+			pending++
+		}
+	}
+	return pending, nil
+}
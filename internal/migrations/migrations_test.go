@@ -0,0 +1,81 @@
+package migrations
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestAll(t *testing.T) {
+	migrations, err := All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+
+	for i, m := range migrations {
+		if m.Version != int64(i+1) {
+			t.Errorf("migration %d_%s out of sequence, want version %d", m.Version, m.Name, i+1)
+		}
+		if m.Name == "" {
+			t.Errorf("migration %d has no name", m.Version)
+		}
+		if strings.TrimSpace(m.Up) == "" || strings.TrimSpace(m.Down) == "" {
+			t.Errorf("migration %d_%s has an empty script", m.Version, m.Name)
+		}
+	}
+
+	if migrations[0].Name != "create_users" {
+		t.Errorf("first migration = %s, want create_users", migrations[0].Name)
+	}
+}
+
+func TestSplitName(t *testing.T) {
+	tests := []struct {
+		file      string
+		base      string
+		direction string
+		ok        bool
+	}{
+		{"0001_create_users.up.sql", "0001_create_users", "up", true},
+		{"0001_create_users.down.sql", "0001_create_users", "down", true},
+		{"0001_create_users.sql", "", "", false},
+		{"0001_create_users.up.txt", "", "", false},
+		{"README.md", "", "", false},
+	}
+
+	for _, tt := range tests {
+		base, direction, ok := splitName(tt.file)
+		if base != tt.base || direction != tt.direction || ok != tt.ok {
+			t.Errorf("splitName(%q) = %q, %q, %v, want %q, %q, %v", tt.file, base, direction, ok, tt.base, tt.direction, tt.ok)
+		}
+	}
+}
+
+// TestBaselineCoversMigrations keeps baseline.sql in step with the migrations it adopts
+func TestBaselineCoversMigrations(t *testing.T) {
+	migrations, err := All()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tables := regexp.MustCompile(`CREATE TABLE (\w+)`)
+	indexes := regexp.MustCompile(`CREATE (UNIQUE )?INDEX (\w+)`)
+	for _, m := range migrations {
+		if m.Version > baselineVersion {
+			break
+		}
+		for _, match := range tables.FindAllStringSubmatch(m.Up, -1) {
+			if !strings.Contains(baselineScript, "CREATE TABLE IF NOT EXISTS "+match[1]+" (") {
+				t.Errorf("baseline.sql does not create table %s from %d_%s", match[1], m.Version, m.Name)
+			}
+		}
+		for _, match := range indexes.FindAllStringSubmatch(m.Up, -1) {
+			if !strings.Contains(baselineScript, "INDEX IF NOT EXISTS "+match[2]+" ") {
+				t.Errorf("baseline.sql does not create index %s from %d_%s", match[2], m.Version, m.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,33 @@
+CREATE TABLE users (
+    id                   BIGSERIAL PRIMARY KEY,
+    email                TEXT NOT NULL,
+    password             TEXT NOT NULL,
+    deposit_amount       NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    role                 TEXT NOT NULL DEFAULT 'customer',
+    full_name            TEXT NOT NULL DEFAULT '',
+    phone                TEXT NOT NULL DEFAULT '',
+    language             TEXT NOT NULL DEFAULT 'en',
+    webhook_url          TEXT NOT NULL DEFAULT '',
+    date_of_birth        TIMESTAMPTZ,
+    address              TEXT NOT NULL DEFAULT '',
+    license_number       TEXT NOT NULL DEFAULT '',
+    license_expiry       TIMESTAMPTZ,
+    kyc_status           TEXT NOT NULL DEFAULT 'none',
+    kyc_reviewed_at      TIMESTAMPTZ,
+    kyc_reject_reason    TEXT NOT NULL DEFAULT '',
+    verified_at          TIMESTAMPTZ,
+    verification_sent_at TIMESTAMPTZ,
+    failed_login_count   INTEGER NOT NULL DEFAULT 0,
+    locked_until         TIMESTAMPTZ,
+    unlock_token_hash    TEXT,
+    totp_secret          TEXT,
+    totp_enabled_at      TIMESTAMPTZ,
+    totp_last_step       BIGINT NOT NULL DEFAULT 0,
+    created_at           TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at           TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT users_email_key UNIQUE (email),
+    CONSTRAINT users_deposit_amount_check CHECK (deposit_amount >= 0)
+);
+
+CREATE INDEX idx_users_kyc_status ON users (kyc_status);
+CREATE INDEX idx_users_unlock_token_hash ON users (unlock_token_hash) WHERE unlock_token_hash IS NOT NULL;
@@ -0,0 +1,11 @@
+CREATE TABLE cars (
+    id                 BIGSERIAL PRIMARY KEY,
+    name               TEXT NOT NULL,
+    stock_availability INTEGER NOT NULL DEFAULT 0,
+    rental_costs       NUMERIC(14, 2) NOT NULL,
+    category           TEXT NOT NULL,
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at         TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_cars_category ON cars (category);
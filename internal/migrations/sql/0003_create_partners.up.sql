@@ -0,0 +1,23 @@
+CREATE TABLE partners (
+    id            BIGSERIAL PRIMARY KEY,
+    name          TEXT NOT NULL,
+    contact_email TEXT NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE api_keys (
+    id           BIGSERIAL PRIMARY KEY,
+    partner_id   BIGINT NOT NULL REFERENCES partners (id) ON DELETE CASCADE,
+    name         TEXT NOT NULL,
+    prefix       TEXT NOT NULL,
+    key_hash     TEXT NOT NULL,
+    scopes       TEXT NOT NULL DEFAULT '',
+    expires_at   TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT api_keys_prefix_key UNIQUE (prefix)
+);
+
+CREATE INDEX idx_api_keys_partner_id ON api_keys (partner_id);
@@ -0,0 +1,37 @@
+CREATE TABLE rental_history (
+    id                  BIGSERIAL PRIMARY KEY,
+    user_id             BIGINT NOT NULL REFERENCES users (id),
+    car_id              BIGINT NOT NULL REFERENCES cars (id),
+    rental_start        TIMESTAMPTZ NOT NULL,
+    rental_end          TIMESTAMPTZ NOT NULL,
+    total_cost          NUMERIC(14, 2) NOT NULL,
+    status              TEXT NOT NULL,
+    partner_id          BIGINT REFERENCES partners (id) ON DELETE SET NULL,
+    pickup_reminded_at  TIMESTAMPTZ,
+    return_reminded_at  TIMESTAMPTZ,
+    overdue_notified_at TIMESTAMPTZ,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at          TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_rental_history_user_id ON rental_history (user_id);
+CREATE INDEX idx_rental_history_car_id ON rental_history (car_id);
+CREATE INDEX idx_rental_history_partner_id ON rental_history (partner_id) WHERE partner_id IS NOT NULL;
+CREATE INDEX idx_rental_history_status_start ON rental_history (status, rental_start);
+CREATE INDEX idx_rental_history_status_end ON rental_history (status, rental_end);
+
+CREATE TABLE payments (
+    id          BIGSERIAL PRIMARY KEY,
+    rental_id   BIGINT NOT NULL REFERENCES rental_history (id) ON DELETE CASCADE,
+    invoice_id  TEXT NOT NULL,
+    amount      NUMERIC(14, 2) NOT NULL,
+    status      TEXT NOT NULL,
+    payment_url TEXT NOT NULL,
+    external_id TEXT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT payments_external_id_key UNIQUE (external_id)
+);
+
+CREATE INDEX idx_payments_rental_id ON payments (rental_id);
+CREATE INDEX idx_payments_invoice_id ON payments (invoice_id);
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS oidc_states;
+DROP TABLE IF EXISTS user_identities;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS rate_limit_hits;
+DROP TABLE IF EXISTS login_attempts;
+DROP TABLE IF EXISTS password_reset_tokens;
+DROP TABLE IF EXISTS revoked_tokens;
+DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS user_sessions;
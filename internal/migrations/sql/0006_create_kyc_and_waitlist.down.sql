@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS car_waitlist;
+DROP TABLE IF EXISTS kyc_documents;
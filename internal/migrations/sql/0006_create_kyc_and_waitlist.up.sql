@@ -0,0 +1,27 @@
+CREATE TABLE kyc_documents (
+    id           BIGSERIAL PRIMARY KEY,
+    user_id      BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    type         TEXT NOT NULL,
+    file_path    TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_kyc_documents_user_id ON kyc_documents (user_id);
+
+CREATE TABLE car_waitlist (
+    id              BIGSERIAL PRIMARY KEY,
+    user_id         BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    car_id          BIGINT NOT NULL REFERENCES cars (id) ON DELETE CASCADE,
+    rental_start    TIMESTAMPTZ NOT NULL,
+    rental_end      TIMESTAMPTZ NOT NULL,
+    status          TEXT NOT NULL,
+    hold_expires_at TIMESTAMPTZ,
+    rental_id       BIGINT REFERENCES rental_history (id) ON DELETE SET NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_car_waitlist_car_status ON car_waitlist (car_id, status, created_at);
+CREATE INDEX idx_car_waitlist_user_id ON car_waitlist (user_id);
+CREATE INDEX idx_car_waitlist_hold_expires_at ON car_waitlist (hold_expires_at) WHERE status = 'offered';
@@ -0,0 +1,47 @@
+CREATE TABLE user_notifications (
+    id           BIGSERIAL PRIMARY KEY,
+    user_id      BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    type         TEXT NOT NULL,
+    email_status TEXT NOT NULL,
+    subject      TEXT NOT NULL,
+    message      TEXT NOT NULL,
+    read_at      TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_user_notifications_user_id ON user_notifications (user_id, created_at DESC);
+CREATE INDEX idx_user_notifications_unread ON user_notifications (user_id) WHERE read_at IS NULL;
+
+CREATE TABLE notification_preferences (
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    type       TEXT NOT NULL,
+    channels   TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX idx_notification_preferences_user_type ON notification_preferences (user_id, type);
+
+CREATE TABLE notification_outbox (
+    id              BIGSERIAL PRIMARY KEY,
+    notification_id BIGINT REFERENCES user_notifications (id) ON DELETE SET NULL,
+    channel         TEXT NOT NULL DEFAULT 'email',
+    type            TEXT NOT NULL DEFAULT '',
+    recipient       TEXT NOT NULL,
+    subject         TEXT NOT NULL,
+    body            TEXT NOT NULL,
+    html_body       TEXT NOT NULL DEFAULT '',
+    status          TEXT NOT NULL,
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    max_attempts    INTEGER NOT NULL,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    locked_until    TIMESTAMPTZ,
+    last_error      TEXT NOT NULL DEFAULT '',
+    sent_at         TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_notification_outbox_status ON notification_outbox (status, next_attempt_at);
+CREATE INDEX idx_notification_outbox_notification_id ON notification_outbox (notification_id);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_endpoints;
+DROP TABLE IF EXISTS event_counts;
+DROP TABLE IF EXISTS audit_logs;
@@ -0,0 +1,54 @@
+CREATE TABLE audit_logs (
+    id          BIGSERIAL PRIMARY KEY,
+    event_type  TEXT NOT NULL,
+    user_id     BIGINT REFERENCES users (id) ON DELETE SET NULL,
+    data        TEXT NOT NULL DEFAULT '',
+    occurred_at TIMESTAMPTZ NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_audit_logs_event_type ON audit_logs (event_type);
+CREATE INDEX idx_audit_logs_user_id ON audit_logs (user_id);
+CREATE INDEX idx_audit_logs_occurred_at ON audit_logs (occurred_at);
+
+CREATE TABLE event_counts (
+    day        DATE NOT NULL,
+    event_type TEXT NOT NULL,
+    count      BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, event_type)
+);
+
+CREATE TABLE webhook_endpoints (
+    id          BIGSERIAL PRIMARY KEY,
+    url         TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    event_types TEXT NOT NULL,
+    secret      TEXT NOT NULL,
+    active      BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE webhook_deliveries (
+    id              BIGSERIAL PRIMARY KEY,
+    endpoint_id     BIGINT NOT NULL REFERENCES webhook_endpoints (id) ON DELETE CASCADE,
+    event_id        TEXT NOT NULL,
+    event_type      TEXT NOT NULL,
+    payload         TEXT NOT NULL,
+    status          TEXT NOT NULL,
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    max_attempts    INTEGER NOT NULL,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    locked_until    TIMESTAMPTZ,
+    response_code   INTEGER,
+    response_body   TEXT NOT NULL DEFAULT '',
+    last_error      TEXT NOT NULL DEFAULT '',
+    delivered_at    TIMESTAMPTZ,
+    redelivery_of   BIGINT REFERENCES webhook_deliveries (id) ON DELETE SET NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_webhook_deliveries_endpoint_id ON webhook_deliveries (endpoint_id, created_at DESC);
+CREATE INDEX idx_webhook_deliveries_event_id ON webhook_deliveries (event_id);
+CREATE INDEX idx_webhook_deliveries_status ON webhook_deliveries (status, next_attempt_at);
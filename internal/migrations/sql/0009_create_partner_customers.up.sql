@@ -0,0 +1,12 @@
+CREATE TABLE partner_customers (
+    id          BIGSERIAL PRIMARY KEY,
+    partner_id  BIGINT NOT NULL REFERENCES partners (id) ON DELETE CASCADE,
+    user_id     BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    approved_at TIMESTAMPTZ,
+    revoked_at  TIMESTAMPTZ,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX idx_partner_customer ON partner_customers (partner_id, user_id);
+CREATE INDEX idx_partner_customers_user_id ON partner_customers (user_id);
@@ -0,0 +1,3 @@
+-- The backfilled accounts cannot be told apart from ones verified by email,
+-- so rolling back leaves every verification in place.
+SELECT 1;
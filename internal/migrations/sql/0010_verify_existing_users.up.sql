@@ -0,0 +1,7 @@
+-- Accounts created before email verification existed were never sent a link.
+-- Treat them as verified instead of locking them out of verified-only actions;
+-- every later registration has verification_sent_at set.
+UPDATE users
+SET verified_at = created_at
+WHERE verified_at IS NULL
+  AND verification_sent_at IS NULL;
@@ -0,0 +1,2 @@
+-- The event streams poll each subscribed user's recent audit log entries
+CREATE INDEX idx_audit_logs_user_created ON audit_logs (user_id, created_at);
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// AuditLog is a persisted domain event
+type AuditLog struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	EventType  string    `gorm:"not null;index" json:"event_type"`
+	UserID     *uint     `gorm:"index" json:"user_id"`
+	Data       string    `gorm:"type:text" json:"-"` // JSON encoded event data
+	OccurredAt time.Time `gorm:"not null;index" json:"occurred_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// EventCount dipakai untuk analitik jumlah event per hari
+type EventCount struct {
+	Day       time.Time `gorm:"primaryKey;type:date" json:"day"`
+	EventType string    `gorm:"primaryKey" json:"event_type"`
+	Count     int64     `gorm:"not null;default:0" json:"count"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// UserIdentity menghubungkan akun OIDC eksternal dengan user
+type UserIdentity struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null" json:"user_id"`
+	Issuer    string    `gorm:"not null;uniqueIndex:idx_identity_subject" json:"issuer"`
+	Subject   string    `gorm:"not null;uniqueIndex:idx_identity_subject" json:"subject"`
+	Email     string    `gorm:"not null" json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+	User      User      `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// OIDCState menyimpan state, nonce dan PKCE verifier selama login OIDC
+type OIDCState struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	State        string    `gorm:"unique;not null" json:"state"`
+	Nonce        string    `gorm:"not null" json:"-"`
+	CodeVerifier string    `gorm:"not null" json:"-"`
+	ExpiresAt    time.Time `gorm:"not null" json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (OIDCState) TableName() string {
+	return "oidc_states"
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+type KYCDocument struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"not null" json:"user_id"`
+	Type        string    `gorm:"not null" json:"type"` // license_front/license_back/selfie
+	FilePath    string    `gorm:"not null" json:"-"`
+	ContentType string    `gorm:"not null" json:"content_type"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (KYCDocument) TableName() string {
+	return "kyc_documents"
+}
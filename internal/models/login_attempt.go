@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+type LoginAttempt struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    *uint     `json:"user_id"`
+	Email     string    `gorm:"not null" json:"email"`
+	IPAddress string    `gorm:"not null" json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	Success   bool      `gorm:"not null" json:"success"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RateLimitHit dipakai oleh rate limit store berbasis database
+type RateLimitHit struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Key       string    `gorm:"index;not null" json:"key"`
+	CreatedAt time.Time `json:"created_at"`
+}
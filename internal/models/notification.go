@@ -1,13 +1,36 @@
 package models
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 type UserNotification struct {
-	ID          uint      `gorm:"primaryKey" json:"id"`
-	UserID      uint      `gorm:"not null" json:"user_id"`
-	Type        string    `gorm:"not null" json:"type"`         // registration/login/topup/rental/return
-	EmailStatus string    `gorm:"not null" json:"email_status"` // sent/failed
-	Message     string    `gorm:"not null" json:"message"`
-	CreatedAt   time.Time `json:"created_at"`
-	User        User      `gorm:"foreignKey:UserID" json:"user"`
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"not null" json:"user_id"`
+	Type        string     `gorm:"not null" json:"type"`         // registration/login/topup/rental/return/reminder/security/waitlist/kyc
+	EmailStatus string     `gorm:"not null" json:"email_status"` // pending/sent/failed/skipped
+	Subject     string     `gorm:"not null" json:"subject"`
+	Message     string     `gorm:"not null" json:"message"`
+	ReadAt      *time.Time `json:"read_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	User        User       `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// NotificationPreference lists the channels a user wants for one notification type
+type NotificationPreference struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_notification_preferences_user_type" json:"user_id"`
+	Type      string    `gorm:"not null;uniqueIndex:idx_notification_preferences_user_type" json:"type"`
+	Channels  string    `gorm:"not null" json:"-"` // comma separated, e.g. email,sms
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ChannelList returns the chosen channels
+func (p NotificationPreference) ChannelList() []string {
+	if p.Channels == "" {
+		return []string{}
+	}
+	return strings.Split(p.Channels, ",")
 }
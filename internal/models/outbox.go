@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// OutboxMessage adalah notifikasi yang menunggu dikirim oleh worker outbox
+type OutboxMessage struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	NotificationID *uint      `json:"notification_id"`
+	Channel        string     `gorm:"not null;default:email" json:"channel"` // email/sms/whatsapp/webhook
+	Type           string     `json:"type"`
+	Recipient      string     `gorm:"not null" json:"recipient"`
+	Subject        string     `gorm:"not null" json:"subject"`
+	Body           string     `gorm:"not null" json:"-"`
+	HTMLBody       string     `json:"-"`
+	Status         string     `gorm:"not null;index" json:"status"` // pending/processing/sent/dead
+	Attempts       int        `gorm:"not null;default:0" json:"attempts"`
+	MaxAttempts    int        `gorm:"not null" json:"max_attempts"`
+	NextAttemptAt  time.Time  `gorm:"not null" json:"next_attempt_at"`
+	LockedUntil    *time.Time `json:"locked_until"`
+	LastError      string     `json:"last_error"`
+	SentAt         *time.Time `json:"sent_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func (OutboxMessage) TableName() string {
+	return "notification_outbox"
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+type Partner struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	Name         string    `gorm:"not null" json:"name"`
+	ContactEmail string    `gorm:"not null" json:"contact_email"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type APIKey struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	PartnerID  uint       `gorm:"not null" json:"partner_id"`
+	Name       string     `gorm:"not null" json:"name"`
+	Prefix     string     `gorm:"unique;not null" json:"prefix"`
+	KeyHash    string     `gorm:"not null" json:"-"`
+	Scopes     string     `gorm:"not null" json:"-"` // comma separated, e.g. cars:read,rentals:write
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Partner    Partner    `gorm:"foreignKey:PartnerID" json:"-"`
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// ScopeList returns the scopes granted to the key
+func (k APIKey) ScopeList() []string {
+	if k.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(k.Scopes, ",")
+}
+
+// HasScope reports whether the key grants the scope
+func (k APIKey) HasScope(scope string) bool {
+	for _, s := range k.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// PartnerCustomer adalah izin dari customer agar partner boleh memesan atas namanya
+type PartnerCustomer struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	PartnerID  uint       `gorm:"not null;uniqueIndex:idx_partner_customer" json:"partner_id"`
+	UserID     uint       `gorm:"not null;uniqueIndex:idx_partner_customer" json:"user_id"`
+	ApprovedAt *time.Time `json:"approved_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	Partner    Partner    `gorm:"foreignKey:PartnerID" json:"-"`
+	User       User       `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// Active reports whether the customer has approved the partner and not revoked it since
+func (l PartnerCustomer) Active() bool {
+	return l.ApprovedAt != nil && l.RevokedAt == nil
+}
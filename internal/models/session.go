@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+type UserSession struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"not null" json:"user_id"`
+	Device     string     `gorm:"not null" json:"device"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `gorm:"not null" json:"ip_address"`
+	LastSeenAt time.Time  `gorm:"not null" json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// RefreshToken dirotasi setiap refresh, semua token satu sesi membentuk satu family
+type RefreshToken struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	UserID       uint       `gorm:"not null" json:"user_id"`
+	SessionID    uint       `gorm:"not null" json:"session_id"`
+	TokenHash    string     `gorm:"unique;not null" json:"-"`
+	ExpiresAt    time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	ReplacedByID *uint      `json:"replaced_by_id"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// RevokedToken adalah denylist jti access token yang sudah di-logout
+type RevokedToken struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	JTI       string    `gorm:"unique;not null" json:"jti"`
+	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
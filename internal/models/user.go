@@ -5,10 +5,30 @@ import (
 )
 
 type User struct {
-	ID            uint      `gorm:"primaryKey" json:"id"`
-	Email         string    `gorm:"unique;not null" json:"email"`
-	Password      string    `gorm:"not null" json:"-"`
-	DepositAmount float64   `gorm:"default:0" json:"deposit_amount"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID                 uint       `gorm:"primaryKey" json:"id"`
+	Email              string     `gorm:"unique;not null" json:"email"`
+	Password           string     `gorm:"not null" json:"-"`
+	DepositAmount      float64    `gorm:"default:0" json:"deposit_amount"`
+	Role               string     `gorm:"not null;default:customer" json:"role"` // customer/admin
+	FullName           string     `json:"full_name"`
+	Phone              string     `json:"phone"`
+	Language           string     `gorm:"not null;default:en" json:"language"` // email language, en/id
+	WebhookURL         string     `json:"webhook_url"`
+	DateOfBirth        *time.Time `json:"date_of_birth"`
+	Address            string     `json:"address"`
+	LicenseNumber      string     `json:"license_number"`
+	LicenseExpiry      *time.Time `json:"license_expiry"`
+	KYCStatus          string     `gorm:"not null;default:none" json:"kyc_status"` // none/pending/approved/rejected
+	KYCReviewedAt      *time.Time `json:"kyc_reviewed_at"`
+	KYCRejectReason    string     `json:"kyc_reject_reason"`
+	VerifiedAt         *time.Time `json:"verified_at"`
+	VerificationSentAt *time.Time `json:"-"`
+	FailedLoginCount   int        `gorm:"default:0" json:"-"`
+	LockedUntil        *time.Time `json:"-"`
+	UnlockTokenHash    *string    `json:"-"`
+	TOTPSecret         *string    `json:"-"`
+	TOTPEnabledAt      *time.Time `json:"-"`
+	TOTPLastStep       int64      `gorm:"default:0" json:"-"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
 }
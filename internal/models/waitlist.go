@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+type CarWaitlist struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	UserID        uint       `gorm:"not null" json:"user_id"`
+	CarID         uint       `gorm:"not null" json:"car_id"`
+	RentalStart   time.Time  `gorm:"not null" json:"rental_start"`
+	RentalEnd     time.Time  `gorm:"not null" json:"rental_end"`
+	Status        string     `gorm:"not null" json:"status"` // waiting/offered/converted/expired/cancelled
+	HoldExpiresAt *time.Time `json:"hold_expires_at"`
+	RentalID      *uint      `json:"rental_id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	User          User       `gorm:"foreignKey:UserID" json:"-"`
+	Car           Car        `gorm:"foreignKey:CarID" json:"car"`
+}
+
+func (CarWaitlist) TableName() string {
+	return "car_waitlist"
+}
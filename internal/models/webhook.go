@@ -0,0 +1,58 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// WebhookEndpoint is an integrator URL that receives signed event deliveries
+type WebhookEndpoint struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	URL         string    `gorm:"not null" json:"url"`
+	Description string    `json:"description"`
+	EventTypes  string    `gorm:"not null" json:"-"` // comma separated, e.g. rental.created,payment.paid
+	Secret      string    `gorm:"not null" json:"-"`
+	Active      bool      `gorm:"not null;default:true" json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// EventTypeList returns the subscribed event types
+func (w WebhookEndpoint) EventTypeList() []string {
+	if w.EventTypes == "" {
+		return []string{}
+	}
+	return strings.Split(w.EventTypes, ",")
+}
+
+// Subscribes reports whether the endpoint wants the event type
+func (w WebhookEndpoint) Subscribes(eventType string) bool {
+	for _, t := range w.EventTypeList() {
+		if t == eventType || t == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one attempt series to deliver an event to an endpoint
+type WebhookDelivery struct {
+	ID            uint            `gorm:"primaryKey" json:"id"`
+	EndpointID    uint            `gorm:"not null;index" json:"endpoint_id"`
+	EventID       string          `gorm:"not null;index" json:"event_id"`
+	EventType     string          `gorm:"not null" json:"event_type"`
+	Payload       string          `gorm:"type:text;not null" json:"-"`
+	Status        string          `gorm:"not null;index" json:"status"` // pending/processing/delivered/dead
+	Attempts      int             `gorm:"not null;default:0" json:"attempts"`
+	MaxAttempts   int             `gorm:"not null" json:"max_attempts"`
+	NextAttemptAt time.Time       `gorm:"not null" json:"next_attempt_at"`
+	LockedUntil   *time.Time      `json:"locked_until"`
+	ResponseCode  *int            `json:"response_code"`
+	ResponseBody  string          `json:"response_body"`
+	LastError     string          `json:"last_error"`
+	DeliveredAt   *time.Time      `json:"delivered_at"`
+	RedeliveryOf  *uint           `json:"redelivery_of"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+	Endpoint      WebhookEndpoint `gorm:"foreignKey:EndpointID" json:"-"`
+}
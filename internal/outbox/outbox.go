@@ -0,0 +1,230 @@
+// Package outbox delivers notifications recorded in the notification_outbox table
+// over their channel (email, SMS, WhatsApp or webhook).
+// Messages are enqueued in the same transaction as the change that caused them
+// and delivered by a worker pool with exponential backoff.
+package outbox
+
+import (
+	"car-rental/internal/metrics"
+	"car-rental/internal/models"
+	"car-rental/internal/services"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxAttempts = 5
+	baseBackoff        = 30 * time.Second
+	maxBackoff         = time.Hour
+	lockDuration       = 2 * time.Minute
+)
+
+// Enqueue stores a notification for delivery on a channel inside the caller's transaction
+func Enqueue(tx *gorm.DB, notificationID *uint, channel string, n services.Notification) error {
+	return tx.Create(&models.OutboxMessage{
+		NotificationID: notificationID,
+		Channel:        channel,
+		Type:           n.Type,
+		Recipient:      n.Recipient,
+		Subject:        n.Subject,
+		Body:           n.Text,
+		HTMLBody:       n.HTML,
+		Status:         "pending",
+		MaxAttempts:    defaultMaxAttempts,
+		NextAttemptAt:  time.Now(),
+	}).Error
+}
+
+// Requeue resets a dead message so it is attempted again
+func Requeue(db *gorm.DB, id uint) error {
+	result := db.Model(&models.OutboxMessage{}).
+		Where("id = ? AND status = ?", id, "dead").
+		Updates(map[string]interface{}{
+			"status":          "pending",
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+			"locked_until":    nil,
+			"last_error":      "",
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Backoff returns the delay before the next attempt, doubling each time with jitter
+func Backoff(attempts int) time.Duration {
+	delay := baseBackoff << uint(attempts-1)
+	if delay > maxBackoff || delay <= 0 {
+		delay = maxBackoff
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// Dispatcher polls the outbox and delivers messages with a pool of workers
+type Dispatcher struct {
+	db        *gorm.DB
+	notifiers map[string]services.Notifier
+	workers   int
+	interval  time.Duration
+	stop      chan struct{}
+	wg        sync.WaitGroup
+}
+
+func NewDispatcher(db *gorm.DB, notifiers map[string]services.Notifier, workers int) *Dispatcher {
+	if workers <= 0 {
+		workers = 1
+	}
+	return &Dispatcher{
+		db:        db,
+		notifiers: notifiers,
+		workers:   workers,
+		interval:  5 * time.Second,
+		stop:      make(chan struct{}),
+	}
+}
+
+// Start launches the workers
+func (d *Dispatcher) Start() {
+	for i := 0; i < d.workers; i++ {
+		d.wg.Add(1)
+		go d.run()
+	}
+}
+
+// Stop waits for in-flight deliveries to finish
+func (d *Dispatcher) Stop() {
+	close(d.stop)
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) run() {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		// Drain everything due before sleeping again
+		for {
+			select {
+			case <-d.stop:
+				return
+			default:
+			}
+
+			msg, err := d.claim()
+			if err != nil {
+				fmt.Printf("Error claiming outbox message: %v\n", err)
+				break
+			}
+			if msg == nil {
+				break
+			}
+			d.deliver(msg)
+		}
+
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim locks the next due message, skipping rows other workers hold
+func (d *Dispatcher) claim() (*models.OutboxMessage, error) {
+	var msg models.OutboxMessage
+	now := time.Now()
+
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		// Pending messages that are due, or processing ones whose worker died
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status = ? AND next_attempt_at <= ?) OR (status = ? AND locked_until < ?)",
+				"pending", now, "processing", now).
+			Order("next_attempt_at ASC").
+			First(&msg).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&msg).Updates(map[string]interface{}{
+			"status":       "processing",
+			"locked_until": now.Add(lockDuration),
+		}).Error
+	})
+
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// deliver sends one message and records the outcome
+func (d *Dispatcher) deliver(msg *models.OutboxMessage) {
+	var sendErr error
+	if notifier, ok := d.notifiers[msg.Channel]; ok {
+		sendErr = notifier.Send(services.Notification{
+			Type:      msg.Type,
+			Recipient: msg.Recipient,
+			Subject:   msg.Subject,
+			Text:      msg.Body,
+			HTML:      msg.HTMLBody,
+		})
+	} else {
+		sendErr = fmt.Errorf("unknown channel %q", msg.Channel)
+	}
+
+	attempts := msg.Attempts + 1
+	updates := map[string]interface{}{
+		"attempts":     attempts,
+		"locked_until": nil,
+	}
+
+	emailStatus := ""
+	result := "failed"
+	switch {
+	case sendErr == nil:
+		updates["status"] = "sent"
+		updates["sent_at"] = time.Now()
+		updates["last_error"] = ""
+		emailStatus = "sent"
+		result = "sent"
+	case attempts >= msg.MaxAttempts:
+		updates["status"] = "dead"
+		updates["last_error"] = sendErr.Error()
+		emailStatus = "failed"
+		result = "dead"
+		fmt.Printf("Outbox message %d dead after %d attempts: %v\n", msg.ID, attempts, sendErr)
+	default:
+		updates["status"] = "pending"
+		updates["last_error"] = sendErr.Error()
+		updates["next_attempt_at"] = time.Now().Add(Backoff(attempts))
+	}
+	metrics.NotificationDeliveries.WithLabelValues(msg.Channel, result).Inc()
+
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(msg).Updates(updates).Error; err != nil {
+			return err
+		}
+		// The notification tracks the status of its email only
+		if emailStatus != "" && msg.Channel == services.ChannelEmail && msg.NotificationID != nil {
+			return tx.Model(&models.UserNotification{}).
+				Where("id = ?", *msg.NotificationID).
+				Update("email_status", emailStatus).Error
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("Error recording outbox delivery %d: %v\n", msg.ID, err)
+	}
+}
@@ -0,0 +1,41 @@
+package outbox
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffBounds(t *testing.T) {
+	tests := []struct {
+		attempts int
+		max      time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{5, 8 * time.Minute},
+		{8, time.Hour}, // 64 minutes, capped
+		{40, time.Hour},
+		{100, time.Hour}, // the shift overflows
+	}
+
+	for _, tt := range tests {
+		// Jitter keeps every delay between half and all of the step
+		for i := 0; i < 100; i++ {
+			got := Backoff(tt.attempts)
+			if got < tt.max/2 || got > tt.max {
+				t.Fatalf("Backoff(%d) = %s, want between %s and %s", tt.attempts, got, tt.max/2, tt.max)
+			}
+		}
+	}
+}
+
+func TestBackoffGrows(t *testing.T) {
+	// The fastest possible next delay is never shorter than the slowest previous one
+	for attempts := 1; attempts < 7; attempts++ {
+		previous := baseBackoff << uint(attempts-1)
+		if next := Backoff(attempts + 1); next < previous {
+			t.Fatalf("Backoff(%d) = %s, shorter than the step before (%s)", attempts+1, next, previous)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package repository
+
+import (
+	"car-rental/internal/models"
+	"car-rental/internal/services"
+	"gorm.io/gorm"
+	"time"
+)
+
+// NewGorm returns repositories backed by the database
+func NewGorm(db *gorm.DB) *Repositories {
+	return &Repositories{
+		Users:         &gormUsers{db: db},
+		Cars:          &gormCars{db: db},
+		Rentals:       &gormRentals{db: db},
+		Payments:      &gormPayments{db: db},
+		Notifications: &gormNotifications{db: db},
+		Waitlist:      &gormWaitlist{db: db},
+	}
+}
+
+// notFound maps GORM's missing record error to ErrNotFound
+func notFound(err error) error {
+	if err == gorm.ErrRecordNotFound {
+		return ErrNotFound
+	}
+	return err
+}
+
+type gormUsers struct {
+	db *gorm.DB
+}
+
+func (r *gormUsers) FindByID(id uint) (*models.User, error) {
+	var user models.User
+	if err := r.db.First(&user, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUsers) FindByEmail(email string) (*models.User, error) {
+	var user models.User
+	if err := r.db.Where("LOWER(email) = ?", services.NormalizeEmail(email)).First(&user).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+type gormCars struct {
+	db *gorm.DB
+}
+
+func (r *gormCars) List(filter CarFilter) ([]models.Car, error) {
+	query := r.db
+	if filter.Category != "" {
+		query = query.Where("category = ?", filter.Category)
+	}
+	if filter.AvailableOnly {
+		query = query.Where("stock_availability > ?", 0)
+	}
+
+	var cars []models.Car
+	if err := query.Find(&cars).Error; err != nil {
+		return nil, err
+	}
+	return cars, nil
+}
+
+func (r *gormCars) FindByID(id uint) (*models.Car, error) {
+	var car models.Car
+	if err := r.db.First(&car, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &car, nil
+}
+
+type gormRentals struct {
+	db *gorm.DB
+}
+
+func (r *gormRentals) Create(rental *models.RentalHistory) error {
+	return r.db.Create(rental).Error
+}
+
+func (r *gormRentals) FindByID(id uint) (*models.RentalHistory, error) {
+	var rental models.RentalHistory
+	if err := r.db.Preload("User").Preload("Car").First(&rental, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &rental, nil
+}
+
+func (r *gormRentals) ListByUser(userID uint) ([]models.RentalHistory, error) {
+	var rentals []models.RentalHistory
+	if err := r.db.Preload("Car").Preload("User").Where("user_id = ?", userID).Find(&rentals).Error; err != nil {
+		return nil, err
+	}
+	return rentals, nil
+}
+
+func (r *gormRentals) ListByPartner(partnerID uint) ([]models.RentalHistory, error) {
+	var rentals []models.RentalHistory
+	if err := r.db.Preload("Car").Where("partner_id = ?", partnerID).Find(&rentals).Error; err != nil {
+		return nil, err
+	}
+	return rentals, nil
+}
+
+type gormPayments struct {
+	db *gorm.DB
+}
+
+func (r *gormPayments) ListByUser(userID uint) ([]models.Payment, error) {
+	var payments []models.Payment
+	if err := r.db.Joins("JOIN rental_history ON rental_history.id = payments.rental_id").
+		Where("rental_history.user_id = ?", userID).
+		Preload("Rental").
+		Preload("Rental.Car").
+		Find(&payments).Error; err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+func (r *gormPayments) FindForUser(id, userID uint) (*models.Payment, error) {
+	var payment models.Payment
+	if err := r.db.Preload("Rental").
+		Preload("Rental.Car").
+		Joins("JOIN rental_history ON rental_history.id = payments.rental_id").
+		Where("payments.id = ? AND rental_history.user_id = ?", id, userID).
+		First(&payment).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &payment, nil
+}
+
+type gormNotifications struct {
+	db *gorm.DB
+}
+
+func (r *gormNotifications) ListByUser(userID uint, unreadOnly bool, limit int) ([]models.UserNotification, error) {
+	query := r.db.Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var notifications []models.UserNotification
+	if err := query.Order("created_at DESC").Limit(limit).Find(&notifications).Error; err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+func (r *gormNotifications) CountUnread(userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.UserNotification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *gormNotifications) MarkRead(id, userID uint) error {
+	var notification models.UserNotification
+	if err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&notification).Error; err != nil {
+		return notFound(err)
+	}
+	if notification.ReadAt != nil {
+		return nil
+	}
+	return r.db.Model(&notification).Update("read_at", time.Now()).Error
+}
+
+func (r *gormNotifications) MarkAllRead(userID uint) error {
+	return r.db.Model(&models.UserNotification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now()).Error
+}
+
+type gormWaitlist struct {
+	db *gorm.DB
+}
+
+func (r *gormWaitlist) Create(entry *models.CarWaitlist) error {
+	return r.db.Create(entry).Error
+}
+
+func (r *gormWaitlist) FindOpen(userID, carID uint) (*models.CarWaitlist, error) {
+	var entry models.CarWaitlist
+	if err := r.db.Where("user_id = ? AND car_id = ? AND status IN ?", userID, carID, []string{"waiting", "offered"}).
+		First(&entry).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &entry, nil
+}
+
+func (r *gormWaitlist) FindHold(userID, carID uint, now time.Time) (*models.CarWaitlist, error) {
+	var entry models.CarWaitlist
+	if err := r.db.Where("user_id = ? AND car_id = ? AND status = ? AND hold_expires_at > ?", userID, carID, "offered", now).
+		First(&entry).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &entry, nil
+}
+
+// CountHolds counts unexpired offers and converted entries whose rental still waits for payment
+func (r *gormWaitlist) CountHolds(carID uint, now time.Time) (int64, error) {
+	pending := r.db.Model(&models.RentalHistory{}).Select("id").Where("status = ?", "pending")
+
+	var count int64
+	err := r.db.Model(&models.CarWaitlist{}).
+		Where("car_id = ?", carID).
+		Where("(status = ? AND hold_expires_at > ?) OR (status = ? AND rental_id IN (?))",
+			"offered", now, "converted", pending).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *gormWaitlist) Position(entry models.CarWaitlist) (int64, error) {
+	var position int64
+	err := r.db.Model(&models.CarWaitlist{}).
+		Where("car_id = ? AND status = ? AND id <= ?", entry.CarID, "waiting", entry.ID).
+		Count(&position).Error
+	return position, err
+}
+
+func (r *gormWaitlist) ListWaiting(carID uint, limit int) ([]models.CarWaitlist, error) {
+	var entries []models.CarWaitlist
+	if err := r.db.Preload("User").
+		Where("car_id = ? AND status = ?", carID, "waiting").
+		Order("created_at ASC, id ASC").
+		Limit(limit).
+		Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (r *gormWaitlist) ListExpiredHolds(now time.Time) ([]models.CarWaitlist, error) {
+	var entries []models.CarWaitlist
+	if err := r.db.Where("status = ? AND hold_expires_at <= ?", "offered", now).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (r *gormWaitlist) Offer(id uint, expiresAt time.Time) error {
+	return r.db.Model(&models.CarWaitlist{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":          "offered",
+		"hold_expires_at": expiresAt,
+	}).Error
+}
+
+func (r *gormWaitlist) SetStatus(id uint, status string) error {
+	return r.db.Model(&models.CarWaitlist{}).Where("id = ?", id).Update("status", status).Error
+}
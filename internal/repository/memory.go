@@ -0,0 +1,433 @@
+package repository
+
+import (
+	"car-rental/internal/models"
+	"car-rental/internal/services"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Memory keeps every record in maps, for tests that should not need a database.
+// Seed it with the Add methods, the repositories fill in associations on read.
+type Memory struct {
+	mu            sync.RWMutex
+	nextID        uint
+	users         map[uint]models.User
+	cars          map[uint]models.Car
+	rentals       map[uint]models.RentalHistory
+	payments      map[uint]models.Payment
+	notifications map[uint]models.UserNotification
+	waitlist      map[uint]models.CarWaitlist
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		users:         map[uint]models.User{},
+		cars:          map[uint]models.Car{},
+		rentals:       map[uint]models.RentalHistory{},
+		payments:      map[uint]models.Payment{},
+		notifications: map[uint]models.UserNotification{},
+		waitlist:      map[uint]models.CarWaitlist{},
+	}
+}
+
+// Repositories returns repositories reading and writing this store
+func (m *Memory) Repositories() *Repositories {
+	return &Repositories{
+		Users:         memoryUsers{m},
+		Cars:          memoryCars{m},
+		Rentals:       memoryRentals{m},
+		Payments:      memoryPayments{m},
+		Notifications: memoryNotifications{m},
+		Waitlist:      memoryWaitlist{m},
+	}
+}
+
+// assignID gives a record without an ID the next free one, callers hold the lock
+func (m *Memory) assignID(id *uint, createdAt *time.Time) {
+	if *id == 0 {
+		m.nextID++
+		*id = m.nextID
+	} else if *id > m.nextID {
+		m.nextID = *id
+	}
+	if createdAt.IsZero() {
+		*createdAt = time.Now()
+	}
+}
+
+func (m *Memory) AddUser(user models.User) models.User {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.assignID(&user.ID, &user.CreatedAt)
+	m.users[user.ID] = user
+	return user
+}
+
+func (m *Memory) AddCar(car models.Car) models.Car {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.assignID(&car.ID, &car.CreatedAt)
+	m.cars[car.ID] = car
+	return car
+}
+
+func (m *Memory) AddRental(rental models.RentalHistory) models.RentalHistory {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.assignID(&rental.ID, &rental.CreatedAt)
+	m.rentals[rental.ID] = rental
+	return rental
+}
+
+func (m *Memory) AddPayment(payment models.Payment) models.Payment {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.assignID(&payment.ID, &payment.CreatedAt)
+	m.payments[payment.ID] = payment
+	return payment
+}
+
+func (m *Memory) AddNotification(notification models.UserNotification) models.UserNotification {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.assignID(&notification.ID, &notification.CreatedAt)
+	m.notifications[notification.ID] = notification
+	return notification
+}
+
+func (m *Memory) AddWaitlistEntry(entry models.CarWaitlist) models.CarWaitlist {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.assignID(&entry.ID, &entry.CreatedAt)
+	m.waitlist[entry.ID] = entry
+	return entry
+}
+
+// WaitlistEntry returns the stored entry, for assertions in tests
+func (m *Memory) WaitlistEntry(id uint) (models.CarWaitlist, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entry, ok := m.waitlist[id]
+	return entry, ok
+}
+
+// withAssociations fills in the rental's User and Car, callers hold the lock
+func (m *Memory) withAssociations(rental models.RentalHistory) models.RentalHistory {
+	rental.User = m.users[rental.UserID]
+	rental.Car = m.cars[rental.CarID]
+	return rental
+}
+
+type memoryUsers struct{ m *Memory }
+
+func (r memoryUsers) FindByID(id uint) (*models.User, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	user, ok := r.m.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+func (r memoryUsers) FindByEmail(email string) (*models.User, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	for _, user := range r.m.users {
+		if services.NormalizeEmail(user.Email) == services.NormalizeEmail(email) {
+			return &user, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+type memoryCars struct{ m *Memory }
+
+func (r memoryCars) List(filter CarFilter) ([]models.Car, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	cars := []models.Car{}
+	for _, car := range r.m.cars {
+		if filter.Category != "" && car.Category != filter.Category {
+			continue
+		}
+		if filter.AvailableOnly && car.StockAvailability <= 0 {
+			continue
+		}
+		cars = append(cars, car)
+	}
+	sort.Slice(cars, func(i, j int) bool { return cars[i].ID < cars[j].ID })
+	return cars, nil
+}
+
+func (r memoryCars) FindByID(id uint) (*models.Car, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	car, ok := r.m.cars[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &car, nil
+}
+
+type memoryRentals struct{ m *Memory }
+
+func (r memoryRentals) Create(rental *models.RentalHistory) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	rental.ID = 0
+	r.m.assignID(&rental.ID, &rental.CreatedAt)
+	rental.UpdatedAt = rental.CreatedAt
+	r.m.rentals[rental.ID] = *rental
+	return nil
+}
+
+func (r memoryRentals) FindByID(id uint) (*models.RentalHistory, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	rental, ok := r.m.rentals[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	rental = r.m.withAssociations(rental)
+	return &rental, nil
+}
+
+func (r memoryRentals) list(match func(models.RentalHistory) bool) []models.RentalHistory {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	rentals := []models.RentalHistory{}
+	for _, rental := range r.m.rentals {
+		if match(rental) {
+			rentals = append(rentals, r.m.withAssociations(rental))
+		}
+	}
+	sort.Slice(rentals, func(i, j int) bool { return rentals[i].ID < rentals[j].ID })
+	return rentals
+}
+
+func (r memoryRentals) ListByUser(userID uint) ([]models.RentalHistory, error) {
+	return r.list(func(rental models.RentalHistory) bool { return rental.UserID == userID }), nil
+}
+
+func (r memoryRentals) ListByPartner(partnerID uint) ([]models.RentalHistory, error) {
+	return r.list(func(rental models.RentalHistory) bool {
+		return rental.PartnerID != nil && *rental.PartnerID == partnerID
+	}), nil
+}
+
+type memoryPayments struct{ m *Memory }
+
+func (r memoryPayments) ListByUser(userID uint) ([]models.Payment, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	payments := []models.Payment{}
+	for _, payment := range r.m.payments {
+		rental, ok := r.m.rentals[payment.RentalID]
+		if !ok || rental.UserID != userID {
+			continue
+		}
+		payment.Rental = r.m.withAssociations(rental)
+		payments = append(payments, payment)
+	}
+	sort.Slice(payments, func(i, j int) bool { return payments[i].ID < payments[j].ID })
+	return payments, nil
+}
+
+func (r memoryPayments) FindForUser(id, userID uint) (*models.Payment, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	payment, ok := r.m.payments[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	rental, ok := r.m.rentals[payment.RentalID]
+	if !ok || rental.UserID != userID {
+		return nil, ErrNotFound
+	}
+	payment.Rental = r.m.withAssociations(rental)
+	return &payment, nil
+}
+
+type memoryNotifications struct{ m *Memory }
+
+func (r memoryNotifications) ListByUser(userID uint, unreadOnly bool, limit int) ([]models.UserNotification, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	notifications := []models.UserNotification{}
+	for _, notification := range r.m.notifications {
+		if notification.UserID != userID || (unreadOnly && notification.ReadAt != nil) {
+			continue
+		}
+		notifications = append(notifications, notification)
+	}
+	sort.Slice(notifications, func(i, j int) bool {
+		return notifications[i].CreatedAt.After(notifications[j].CreatedAt)
+	})
+	if limit > 0 && len(notifications) > limit {
+		notifications = notifications[:limit]
+	}
+	return notifications, nil
+}
+
+func (r memoryNotifications) CountUnread(userID uint) (int64, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	var count int64
+	for _, notification := range r.m.notifications {
+		if notification.UserID == userID && notification.ReadAt == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r memoryNotifications) MarkRead(id, userID uint) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	notification, ok := r.m.notifications[id]
+	if !ok || notification.UserID != userID {
+		return ErrNotFound
+	}
+	if notification.ReadAt == nil {
+		now := time.Now()
+		notification.ReadAt = &now
+		r.m.notifications[id] = notification
+	}
+	return nil
+}
+
+func (r memoryNotifications) MarkAllRead(userID uint) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	now := time.Now()
+	for id, notification := range r.m.notifications {
+		if notification.UserID == userID && notification.ReadAt == nil {
+			notification.ReadAt = &now
+			r.m.notifications[id] = notification
+		}
+	}
+	return nil
+}
+
+type memoryWaitlist struct{ m *Memory }
+
+func (r memoryWaitlist) Create(entry *models.CarWaitlist) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	entry.ID = 0
+	r.m.assignID(&entry.ID, &entry.CreatedAt)
+	entry.UpdatedAt = entry.CreatedAt
+	r.m.waitlist[entry.ID] = *entry
+	return nil
+}
+
+// find returns the matching entries oldest first, callers hold the lock
+func (r memoryWaitlist) find(match func(models.CarWaitlist) bool) []models.CarWaitlist {
+	entries := []models.CarWaitlist{}
+	for _, entry := range r.m.waitlist {
+		if match(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries
+}
+
+func (r memoryWaitlist) first(match func(models.CarWaitlist) bool) (*models.CarWaitlist, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	entries := r.find(match)
+	if len(entries) == 0 {
+		return nil, ErrNotFound
+	}
+	return &entries[0], nil
+}
+
+func (r memoryWaitlist) FindOpen(userID, carID uint) (*models.CarWaitlist, error) {
+	return r.first(func(e models.CarWaitlist) bool {
+		return e.UserID == userID && e.CarID == carID && (e.Status == "waiting" || e.Status == "offered")
+	})
+}
+
+func (r memoryWaitlist) FindHold(userID, carID uint, now time.Time) (*models.CarWaitlist, error) {
+	return r.first(func(e models.CarWaitlist) bool {
+		return e.UserID == userID && e.CarID == carID && e.Status == "offered" && e.HoldExpiresAt != nil && e.HoldExpiresAt.After(now)
+	})
+}
+
+func (r memoryWaitlist) CountHolds(carID uint, now time.Time) (int64, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	holds := r.find(func(e models.CarWaitlist) bool {
+		if e.CarID != carID {
+			return false
+		}
+		if e.Status == "offered" {
+			return e.HoldExpiresAt != nil && e.HoldExpiresAt.After(now)
+		}
+		if e.Status == "converted" && e.RentalID != nil {
+			rental, ok := r.m.rentals[*e.RentalID]
+			return ok && rental.Status == "pending"
+		}
+		return false
+	})
+	return int64(len(holds)), nil
+}
+
+func (r memoryWaitlist) Position(entry models.CarWaitlist) (int64, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	ahead := r.find(func(e models.CarWaitlist) bool {
+		return e.CarID == entry.CarID && e.Status == "waiting" && e.ID <= entry.ID
+	})
+	return int64(len(ahead)), nil
+}
+
+func (r memoryWaitlist) ListWaiting(carID uint, limit int) ([]models.CarWaitlist, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	entries := r.find(func(e models.CarWaitlist) bool { return e.CarID == carID && e.Status == "waiting" })
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].CreatedAt.Before(entries[j].CreatedAt) })
+	if limit >= 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	for i := range entries {
+		entries[i].User = r.m.users[entries[i].UserID]
+	}
+	return entries, nil
+}
+
+func (r memoryWaitlist) ListExpiredHolds(now time.Time) ([]models.CarWaitlist, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	return r.find(func(e models.CarWaitlist) bool {
+		return e.Status == "offered" && e.HoldExpiresAt != nil && !e.HoldExpiresAt.After(now)
+	}), nil
+}
+
+func (r memoryWaitlist) update(id uint, change func(*models.CarWaitlist)) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	entry, ok := r.m.waitlist[id]
+	if !ok {
+		return ErrNotFound
+	}
+	change(&entry)
+	entry.UpdatedAt = time.Now()
+	r.m.waitlist[id] = entry
+	return nil
+}
+
+func (r memoryWaitlist) Offer(id uint, expiresAt time.Time) error {
+	return r.update(id, func(e *models.CarWaitlist) {
+		e.Status = "offered"
+		e.HoldExpiresAt = &expiresAt
+	})
+}
+
+func (r memoryWaitlist) SetStatus(id uint, status string) error {
+	return r.update(id, func(e *models.CarWaitlist) { e.Status = status })
+}
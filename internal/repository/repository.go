@@ -0,0 +1,73 @@
+// Package repository hides data access behind interfaces, main wires the
+// handlers with the GORM implementations.
+package repository
+
+import (
+	"car-rental/internal/models"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned when a record does not exist or is not visible to the caller
+var ErrNotFound = errors.New("record not found")
+
+// CarFilter narrows a car listing
+type CarFilter struct {
+	Category      string
+	AvailableOnly bool
+}
+
+type UserRepository interface {
+	FindByID(id uint) (*models.User, error)
+	FindByEmail(email string) (*models.User, error)
+}
+
+type CarRepository interface {
+	List(filter CarFilter) ([]models.Car, error)
+	FindByID(id uint) (*models.Car, error)
+}
+
+// RentalRepository loads rentals with their User and Car, partners only get the Car
+type RentalRepository interface {
+	Create(rental *models.RentalHistory) error
+	FindByID(id uint) (*models.RentalHistory, error)
+	ListByUser(userID uint) ([]models.RentalHistory, error)
+	ListByPartner(partnerID uint) ([]models.RentalHistory, error)
+}
+
+// PaymentRepository loads payments with their Rental and its Car
+type PaymentRepository interface {
+	ListByUser(userID uint) ([]models.Payment, error)
+	FindForUser(id, userID uint) (*models.Payment, error)
+}
+
+type NotificationRepository interface {
+	ListByUser(userID uint, unreadOnly bool, limit int) ([]models.UserNotification, error)
+	CountUnread(userID uint) (int64, error)
+	MarkRead(id, userID uint) error
+	MarkAllRead(userID uint) error
+}
+
+// WaitlistRepository keeps the queue for sold-out cars. A hold is an offered
+// entry that reserves a unit until it expires or its rental is paid.
+type WaitlistRepository interface {
+	Create(entry *models.CarWaitlist) error
+	FindOpen(userID, carID uint) (*models.CarWaitlist, error)
+	FindHold(userID, carID uint, now time.Time) (*models.CarWaitlist, error)
+	CountHolds(carID uint, now time.Time) (int64, error)
+	Position(entry models.CarWaitlist) (int64, error)
+	ListWaiting(carID uint, limit int) ([]models.CarWaitlist, error)
+	ListExpiredHolds(now time.Time) ([]models.CarWaitlist, error)
+	Offer(id uint, expiresAt time.Time) error
+	SetStatus(id uint, status string) error
+}
+
+// Repositories groups the repositories handed to the handlers
+type Repositories struct {
+	Users         UserRepository
+	Cars          CarRepository
+	Rentals       RentalRepository
+	Payments      PaymentRepository
+	Notifications NotificationRepository
+	Waitlist      WaitlistRepository
+}
@@ -1,35 +1,60 @@
 package services
 
 import (
+	"car-rental/internal/config"
+	"context"
+	"errors"
 	"fmt"
 	"gopkg.in/gomail.v2"
-	"os"
+	"net"
 	"strconv"
+	"strings"
 )
 
+// NormalizeEmail is applied to every address before it is stored or looked up
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Mailer sends email, EmailService is the SMTP implementation
+type Mailer interface {
+	SendEmail(to, subject, body string) error
+	SendMultipart(to, subject, text, html string) error
+}
+
 type EmailService struct {
 	dialer *gomail.Dialer
+	from   string
 }
 
-func NewEmailService() *EmailService {
-	port, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
-	if err != nil {
-		port = 587 // default port
-	}
-
+func NewEmailService(cfg config.SMTP) *EmailService {
 	d := gomail.NewDialer(
-		os.Getenv("SMTP_HOST"),
-		port,
-		os.Getenv("SMTP_USER"),
-		os.Getenv("SMTP_PASSWORD"),
+		cfg.Host,
+		cfg.Port,
+		cfg.User,
+		cfg.Password.Value(),
 	)
 
-	return &EmailService{dialer: d}
+	return &EmailService{dialer: d, from: cfg.From}
+}
+
+// Ping checks that the SMTP server accepts connections
+func (s *EmailService) Ping(ctx context.Context) error {
+	if s.dialer.Host == "" {
+		return errors.New("SMTP_HOST is not configured")
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(s.dialer.Host, strconv.Itoa(s.dialer.Port)))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
 }
 
 func (s *EmailService) SendEmail(to, subject, body string) error {
 	m := gomail.NewMessage()
-	m.SetHeader("From", os.Getenv("SMTP_USER"))
+	m.SetHeader("From", s.from)
 	m.SetHeader("To", to)
 	m.SetHeader("Subject", subject)
 	m.SetBody("text/html", body)
@@ -42,3 +67,21 @@ func (s *EmailService) SendEmail(to, subject, body string) error {
 	fmt.Println("Email sent successfully")
 	return nil
 }
+
+// SendMultipart sends a plain-text email with an HTML alternative
+func (s *EmailService) SendMultipart(to, subject, text, html string) error {
+	m := gomail.NewMessage()
+	m.SetHeader("From", s.from)
+	m.SetHeader("To", to)
+	m.SetHeader("Subject", subject)
+	m.SetBody("text/plain", text)
+	m.AddAlternative("text/html", html)
+
+	fmt.Printf("Sending email to %s with subject: %s\n", to, subject)
+	if err := s.dialer.DialAndSend(m); err != nil {
+		fmt.Printf("Error sending email: %v\n", err)
+		return err
+	}
+	fmt.Println("Email sent successfully")
+	return nil
+}
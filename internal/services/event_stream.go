@@ -0,0 +1,26 @@
+package services
+
+import (
+	"errors"
+	"time"
+)
+
+const eventStreamPurpose = "event_stream"
+
+// EventStreamTokenTTL is how long a stream token can be used to open a stream.
+// It travels in the URL because EventSource cannot send headers, so it is kept short.
+const EventStreamTokenTTL = time.Minute
+
+// GenerateEventStreamToken signs the token that opens the user's event stream
+func GenerateEventStreamToken(userID uint) (string, error) {
+	return signPurposeToken(userID, eventStreamPurpose, EventStreamTokenTTL, nil)
+}
+
+// ParseEventStreamToken validates a stream token and returns its user
+func ParseEventStreamToken(tokenString string) (uint, error) {
+	userID, _, err := parsePurposeToken(tokenString, eventStreamPurpose)
+	if err != nil {
+		return 0, errors.New("invalid event stream token")
+	}
+	return userID, nil
+}
@@ -0,0 +1,27 @@
+package services
+
+import "testing"
+
+func TestEventStreamToken(t *testing.T) {
+	useTestSecret(t)
+
+	token, err := GenerateEventStreamToken(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID, err := ParseEventStreamToken(token); err != nil || userID != 7 {
+		t.Fatalf("ParseEventStreamToken = %d, %v, want user 7", userID, err)
+	}
+
+	// An emailed link must not open a stream, nor the other way round
+	link, err := GenerateVerificationToken(7, "rider@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseEventStreamToken(link); err == nil {
+		t.Error("verification token opened an event stream")
+	}
+	if _, _, err := ParseVerificationToken(token); err == nil {
+		t.Error("stream token accepted as a verification token")
+	}
+}
@@ -1,17 +1,119 @@
 package services
 
 import (
+	"car-rental/internal/config"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"github.com/golang-jwt/jwt/v5"
-	"os"
 	"time"
 )
 
-func GenerateJWT(userID uint) (string, error) {
+// jwtSecret signs HS256 access tokens and every purpose token
+var (
+	jwtSecret       []byte
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// ConfigureJWT sets the shared secret and token lifetimes
+func ConfigureJWT(cfg config.JWT) {
+	jwtSecret = []byte(cfg.Secret.Value())
+	accessTokenTTL = cfg.AccessTokenTTL
+	refreshTokenTTL = cfg.RefreshTokenTTL
+}
+
+// AccessTokenTTL returns the lifetime of access tokens
+func AccessTokenTTL() time.Duration {
+	return accessTokenTTL
+}
+
+// RefreshTokenTTL returns the lifetime of refresh tokens
+func RefreshTokenTTL() time.Duration {
+	return refreshTokenTTL
+}
+
+func GenerateJWT(userID, sessionID uint) (string, error) {
+	jti, err := RandomToken(16)
+	if err != nil {
+		return "", err
+	}
+
 	claims := jwt.MapClaims{
 		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(), // Token expires in 24 hours
+		"sid":     sessionID,
+		"jti":     jti,
+		"exp":     time.Now().Add(AccessTokenTTL()).Unix(),
+	}
+
+	if Keys != nil {
+		return Keys.Sign(claims)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(jwtSecret)
+}
+
+// ParseJWT verifies an access token, rejecting any algorithm other than the configured one
+func ParseJWT(tokenString string) (*jwt.Token, error) {
+	if Keys != nil {
+		return Keys.Parse(tokenString)
+	}
+
+	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return jwtSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+}
+
+// RandomToken returns n random bytes encoded as hex
+func RandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// HashToken hashes an opaque token for storage
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// signPurposeToken signs a short-lived token usable only for the given purpose
+func signPurposeToken(userID uint, purpose string, ttl time.Duration, extra jwt.MapClaims) (string, error) {
+	claims := jwt.MapClaims{
+		"sub":     userID,
+		"purpose": purpose,
+		"exp":     time.Now().Add(ttl).Unix(),
+	}
+	for k, v := range extra {
+		claims[k] = v
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	return token.SignedString(jwtSecret)
+}
+
+// parsePurposeToken validates a purpose token and returns its user and claims
+func parsePurposeToken(tokenString, purpose string) (uint, jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return jwtSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !token.Valid {
+		return 0, nil, errors.New("invalid token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != purpose {
+		return 0, nil, errors.New("invalid token")
+	}
+
+	sub, ok := claims["sub"].(float64)
+	if !ok {
+		return 0, nil, errors.New("invalid token")
+	}
+
+	return uint(sub), claims, nil
 }
@@ -0,0 +1,372 @@
+package services
+
+import (
+	"car-rental/internal/config"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// signingKey is one key from the key directory
+type signingKey struct {
+	kid       string
+	alg       string
+	private   crypto.Signer
+	public    crypto.PublicKey
+	createdAt time.Time
+	generated bool // written by Rotate, only these are pruned
+}
+
+// generatedKID matches the kids Rotate gives its keys, e.g. 20240102150405-rs256
+var generatedKID = regexp.MustCompile(`^(\d{14})-(rs256|eddsa)$`)
+
+// KeyManager holds the active signing key and every key still accepted for verification
+type KeyManager struct {
+	mu      sync.RWMutex
+	dir     string
+	alg     string
+	pinned  string
+	keys    map[string]*signingKey
+	current *signingKey
+}
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// Keys is nil when tokens are signed with the shared JWT_SECRET
+var Keys *KeyManager
+
+// InitKeys loads asymmetric signing keys from JWT_KEY_DIR, if configured
+func InitKeys(cfg config.JWT) error {
+	if cfg.KeyDir == "" {
+		fmt.Println("JWT_KEY_DIR not set, signing tokens with HS256")
+		return nil
+	}
+
+	if cfg.SigningAlg != "RS256" && cfg.SigningAlg != "EdDSA" {
+		return fmt.Errorf("unsupported JWT_SIGNING_ALG %q", cfg.SigningAlg)
+	}
+
+	km := &KeyManager{dir: cfg.KeyDir, alg: cfg.SigningAlg, pinned: cfg.SigningKID}
+	if err := km.Reload(); err != nil {
+		return err
+	}
+
+	// A pinned key must exist, a generated one would be dropped by the next Reload
+	if km.pinned != "" && km.current == nil {
+		return fmt.Errorf("JWT_SIGNING_KID %q has no private key in %s", km.pinned, km.dir)
+	}
+
+	// First start with an empty directory
+	if km.current == nil {
+		if err := km.Rotate(); err != nil {
+			return err
+		}
+	}
+
+	Keys = km
+	fmt.Printf("Loaded %d JWT keys, signing with %s\n", len(km.keys), km.current.kid)
+	return nil
+}
+
+// Reload reads every key in the directory, private keys are *.pem and verification-only keys *.pub.pem
+func (km *KeyManager) Reload() error {
+	if err := os.MkdirAll(km.dir, 0700); err != nil {
+		return err
+	}
+
+	files, err := filepath.Glob(filepath.Join(km.dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	keys := map[string]*signingKey{}
+	var current *signingKey
+	for _, file := range files {
+		key, err := loadKey(file)
+		if err != nil {
+			return fmt.Errorf("load key %s: %w", file, err)
+		}
+		keys[key.kid] = key
+
+		// Newest private key signs, unless pinned by JWT_SIGNING_KID
+		if key.private == nil {
+			continue
+		}
+		if km.pinned != "" {
+			if key.kid == km.pinned {
+				current = key
+			}
+			continue
+		}
+		if current == nil || key.createdAt.After(current.createdAt) {
+			current = key
+		}
+	}
+
+	km.mu.Lock()
+	km.keys = keys
+	km.current = current
+	km.mu.Unlock()
+	return nil
+}
+
+// Rotate generates a new signing key; older keys stay valid for verification
+func (km *KeyManager) Rotate() error {
+	var signer crypto.Signer
+	var err error
+	switch km.alg {
+	case "EdDSA":
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	if err != nil {
+		return err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(signer)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	kid := fmt.Sprintf("%s-%s", now.Format("20060102150405"), strings.ToLower(km.alg))
+	file := filepath.Join(km.dir, kid+".pem")
+	if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		return err
+	}
+
+	key := &signingKey{kid: kid, alg: km.alg, private: signer, public: signer.Public(), createdAt: now, generated: true}
+
+	km.mu.Lock()
+	if km.keys == nil {
+		km.keys = map[string]*signingKey{}
+	}
+	km.keys[kid] = key
+	km.current = key
+	km.mu.Unlock()
+
+	fmt.Printf("Rotated JWT signing key, new kid: %s\n", kid)
+	return nil
+}
+
+// Prune deletes retired keys that Rotate generated before the cutoff,
+// keys placed in the directory by an operator are never removed
+func (km *KeyManager) Prune(before time.Time) {
+	km.mu.Lock()
+	defer km.mu.Unlock()
+
+	for kid, key := range km.keys {
+		if !key.generated || key == km.current || !key.createdAt.Before(before) {
+			continue
+		}
+
+		file := filepath.Join(km.dir, kid+".pem")
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Error removing JWT key %s: %v\n", kid, err)
+			continue
+		}
+		delete(km.keys, kid)
+		fmt.Printf("Pruned JWT key %s\n", kid)
+	}
+}
+
+// StartRotation rotates the signing key every interval and prunes keys after one more interval,
+// it returns when ctx is cancelled. With JWT_SIGNING_KID pinned it only reloads the directory.
+func (km *KeyManager) StartRotation(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Pick up keys rotated by other instances sharing the directory
+		if err := km.Reload(); err != nil {
+			fmt.Printf("Error reloading JWT keys: %v\n", err)
+			continue
+		}
+		if km.pinned != "" {
+			continue
+		}
+
+		km.mu.RLock()
+		due := km.current == nil || time.Since(km.current.createdAt) >= interval
+		km.mu.RUnlock()
+
+		if due {
+			if err := km.Rotate(); err != nil {
+				fmt.Printf("Error rotating JWT key: %v\n", err)
+				continue
+			}
+		}
+		km.Prune(time.Now().Add(-2 * interval))
+	}
+}
+
+// Sign signs claims with the current key and sets the kid header
+func (km *KeyManager) Sign(claims jwt.Claims) (string, error) {
+	km.mu.RLock()
+	key := km.current
+	km.mu.RUnlock()
+
+	if key == nil {
+		return "", errors.New("no signing key available")
+	}
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.alg), claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.private)
+}
+
+// lookup returns the verification key for a kid
+func (km *KeyManager) lookup(kid string) (*signingKey, bool) {
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+	key, ok := km.keys[kid]
+	return key, ok
+}
+
+// Parse verifies a token, only accepting the algorithm of the key named by its kid
+func (km *KeyManager) Parse(tokenString string) (*jwt.Token, error) {
+	// Look up kid without verifying, then parse again pinned to that key's algorithm
+	unverified, _, err := jwt.NewParser().ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		return nil, err
+	}
+
+	kid, _ := unverified.Header["kid"].(string)
+	key, ok := km.lookup(kid)
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+
+	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return key.public, nil
+	}, jwt.WithValidMethods([]string{key.alg}))
+}
+
+// JWKS returns the public keys for the /.well-known/jwks.json document
+func (km *KeyManager) JWKS() []JWK {
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+
+	jwks := []JWK{}
+	for _, key := range km.keys {
+		jwk := JWK{Kid: key.kid, Use: "sig", Alg: key.alg}
+		switch pub := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		jwks = append(jwks, jwk)
+	}
+	return jwks
+}
+
+// loadKey parses a PEM key file, the kid is the file name without extension
+func loadKey(file string) (*signingKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	base := filepath.Base(file)
+	key := &signingKey{
+		kid:       strings.TrimSuffix(strings.TrimSuffix(base, ".pem"), ".pub"),
+		createdAt: info.ModTime(),
+	}
+
+	// Keys written by Rotate carry their creation time in the kid
+	if match := generatedKID.FindStringSubmatch(key.kid); match != nil && base == key.kid+".pem" {
+		if createdAt, err := time.Parse("20060102150405", match[1]); err == nil {
+			key.createdAt = createdAt
+			key.generated = true
+		}
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := parsed.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("unsupported private key")
+		}
+		key.private = signer
+		key.public = signer.Public()
+	case "RSA PRIVATE KEY":
+		parsed, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key.private = parsed
+		key.public = parsed.Public()
+	case "PUBLIC KEY":
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key.public = parsed
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+
+	switch key.public.(type) {
+	case *rsa.PublicKey:
+		key.alg = "RS256"
+	case ed25519.PublicKey:
+		key.alg = "EdDSA"
+	default:
+		return nil, errors.New("unsupported key type")
+	}
+
+	return key, nil
+}
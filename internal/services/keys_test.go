@@ -0,0 +1,94 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeOperatorKeys places an Ed25519 private key and a verification-only public key in dir
+func writeOperatorKeys(t *testing.T, dir string) {
+	t.Helper()
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]*pem.Block{
+		"operator.pem":    {Type: "PRIVATE KEY", Bytes: privateDER},
+		"partner.pub.pem": {Type: "PUBLIC KEY", Bytes: publicDER},
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	for name, block := range files {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPruneKeepsOperatorKeys(t *testing.T) {
+	dir := t.TempDir()
+	writeOperatorKeys(t, dir)
+
+	km := &KeyManager{dir: dir, alg: "EdDSA"}
+	if err := km.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	retired := km.current.kid
+	if err := km.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	km.current = km.keys["operator"]
+
+	km.Prune(time.Now().Add(time.Hour))
+
+	for _, kid := range []string{"operator", "partner"} {
+		if _, ok := km.keys[kid]; !ok {
+			t.Errorf("operator key %s was pruned", kid)
+		}
+	}
+	if _, ok := km.keys[retired]; ok {
+		t.Errorf("generated key %s was not pruned", retired)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "partner.pub.pem")); err != nil {
+		t.Errorf("partner.pub.pem: %v", err)
+	}
+}
+
+func TestPinnedKeySurvivesReload(t *testing.T) {
+	dir := t.TempDir()
+	writeOperatorKeys(t, dir)
+
+	km := &KeyManager{dir: dir, alg: "EdDSA", pinned: "operator"}
+	if err := km.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if err := km.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := km.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if km.current == nil || km.current.kid != "operator" {
+		t.Fatalf("current key = %v, want the pinned operator key", km.current)
+	}
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"car-rental/internal/config"
+	"errors"
+	"fmt"
+)
+
+// Notification channels
+const (
+	ChannelEmail    = "email"
+	ChannelSMS      = "sms"
+	ChannelWhatsApp = "whatsapp"
+	ChannelWebhook  = "webhook"
+)
+
+// Channels lists every supported notification channel
+var Channels = []string{ChannelEmail, ChannelSMS, ChannelWhatsApp, ChannelWebhook}
+
+// ErrChannelUnavailable is returned for channels that are not configured on this deployment
+var ErrChannelUnavailable = errors.New("notification channel is not configured")
+
+// Notification is one message for one recipient on one channel
+type Notification struct {
+	Type      string // notification type, e.g. rental or security
+	Recipient string // email address, phone number or webhook URL
+	Subject   string
+	Text      string
+	HTML      string
+}
+
+// Notifier delivers notifications over a single channel
+type Notifier interface {
+	Channel() string
+	Send(n Notification) error
+}
+
+// NewNotifiers returns a notifier for every available channel, keyed by channel
+func NewNotifiers(mailer Mailer, sms config.SMS) map[string]Notifier {
+	notifiers := map[string]Notifier{}
+	for _, n := range []Notifier{
+		NewEmailNotifier(mailer),
+		NewSMSNotifier(ChannelSMS, sms),
+		NewSMSNotifier(ChannelWhatsApp, sms),
+		NewWebhookNotifier(),
+	} {
+		if ChannelAvailable(n.Channel(), sms) {
+			notifiers[n.Channel()] = n
+		}
+	}
+	return notifiers
+}
+
+// ChannelAvailable reports whether a channel can deliver, SMS and WhatsApp need
+// a provider or, in development, the logging stub
+func ChannelAvailable(channel string, sms config.SMS) bool {
+	switch channel {
+	case ChannelSMS, ChannelWhatsApp:
+		return sms.ProviderURL != "" || sms.Stub
+	default:
+		return ValidChannel(channel)
+	}
+}
+
+// AvailableChannels lists the channels users can choose on this deployment
+func AvailableChannels(sms config.SMS) []string {
+	available := []string{}
+	for _, channel := range Channels {
+		if ChannelAvailable(channel, sms) {
+			available = append(available, channel)
+		}
+	}
+	return available
+}
+
+// ValidChannel reports whether the channel is supported
+func ValidChannel(channel string) bool {
+	for _, c := range Channels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// EmailNotifier sends notifications through SMTP
+type EmailNotifier struct {
+	email Mailer
+}
+
+func NewEmailNotifier(mailer Mailer) *EmailNotifier {
+	return &EmailNotifier{email: mailer}
+}
+
+func (n *EmailNotifier) Channel() string {
+	return ChannelEmail
+}
+
+func (n *EmailNotifier) Send(notification Notification) error {
+	if notification.HTML != "" {
+		return n.email.SendMultipart(notification.Recipient, notification.Subject, notification.Text, notification.HTML)
+	}
+	if notification.Text == "" {
+		return fmt.Errorf("empty email body")
+	}
+	return n.email.SendEmail(notification.Recipient, notification.Subject, notification.Text)
+}
@@ -0,0 +1,308 @@
+package services
+
+import (
+	"car-rental/internal/config"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OIDCService runs the authorization code flow against an OpenID Connect provider
+type OIDCService struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	client       *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]interface{}
+	keysAt    time.Time
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCClaims are the ID token claims used for account linking
+type OIDCClaims struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+func NewOIDCService(cfg config.OIDC) *OIDCService {
+	return &OIDCService{
+		issuer:       strings.TrimSuffix(cfg.Issuer, "/"),
+		clientID:     cfg.ClientID,
+		clientSecret: cfg.ClientSecret.Value(),
+		redirectURL:  cfg.RedirectURL,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Enabled reports whether OIDC login is configured
+func (s *OIDCService) Enabled() bool {
+	return s.issuer != "" && s.clientID != "" && s.redirectURL != ""
+}
+
+// PKCEChallenge derives the S256 code challenge for a verifier
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// getJSON fetches a JSON document
+func (s *OIDCService) getJSON(endpoint string, out interface{}) error {
+	resp, err := s.client.Get(endpoint)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %d", endpoint, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// discover loads and caches the provider metadata
+func (s *OIDCService) discover() (*oidcDiscovery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.discovery != nil {
+		return s.discovery, nil
+	}
+
+	var doc oidcDiscovery
+	if err := s.getJSON(s.issuer+"/.well-known/openid-configuration", &doc); err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != s.issuer {
+		return nil, fmt.Errorf("issuer mismatch: %s", doc.Issuer)
+	}
+
+	s.discovery = &doc
+	return s.discovery, nil
+}
+
+// AuthURL builds the authorization request URL
+func (s *OIDCService) AuthURL(state, nonce, codeVerifier string) (string, error) {
+	doc, err := s.discover()
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", s.clientID)
+	params.Set("redirect_uri", s.redirectURL)
+	params.Set("scope", "openid email profile")
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", PKCEChallenge(codeVerifier))
+	params.Set("code_challenge_method", "S256")
+
+	return doc.AuthorizationEndpoint + "?" + params.Encode(), nil
+}
+
+// Exchange trades the authorization code for an ID token and validates it
+func (s *OIDCService) Exchange(code, codeVerifier, nonce string) (*OIDCClaims, error) {
+	doc, err := s.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", s.redirectURL)
+	form.Set("client_id", s.clientID)
+	form.Set("client_secret", s.clientSecret)
+	form.Set("code_verifier", codeVerifier)
+
+	resp, err := s.client.PostForm(doc.TokenEndpoint, form)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned %d", resp.StatusCode)
+	}
+
+	var tokenResp struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return nil, err
+	}
+	if tokenResp.IDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	return s.validateIDToken(tokenResp.IDToken, nonce)
+}
+
+// validateIDToken checks signature, issuer, audience, expiry and nonce
+func (s *OIDCService) validateIDToken(idToken, nonce string) (*OIDCClaims, error) {
+	token, err := jwt.Parse(idToken, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return s.key(kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "EdDSA"}),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithAudience(s.clientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("invalid id token: %w", err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("invalid id token claims")
+	}
+
+	if claims["nonce"] != nonce {
+		return nil, errors.New("id token nonce mismatch")
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, errors.New("id token has no subject")
+	}
+
+	email, _ := claims["email"].(string)
+	emailVerified, _ := claims["email_verified"].(bool)
+
+	return &OIDCClaims{
+		Issuer:        s.issuer,
+		Subject:       subject,
+		Email:         NormalizeEmail(email),
+		EmailVerified: emailVerified,
+	}, nil
+}
+
+// key returns the provider's verification key for a kid, refetching the JWKS on a miss
+func (s *OIDCService) key(kid string) (interface{}, error) {
+	s.mu.Lock()
+	key, ok := s.keys[kid]
+	stale := time.Since(s.keysAt) > time.Minute
+	s.mu.Unlock()
+
+	if ok {
+		return key, nil
+	}
+	if !stale {
+		return nil, errors.New("unknown signing key")
+	}
+
+	doc, err := s.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	var set struct {
+		Keys []JWK `json:"keys"`
+	}
+	if err := s.getJSON(doc.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]interface{}{}
+	for _, jwk := range set.Keys {
+		pub, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = pub
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.keysAt = time.Now()
+	s.mu.Unlock()
+
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, errors.New("unknown signing key")
+}
+
+// PublicKey converts a JWK into an RSA or Ed25519 public key
+func (k JWK) PublicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
+
+const oidcLinkPurpose = "link_oidc"
+
+// GenerateOIDCLinkToken signs the emailed link that confirms linking an identity to an existing account
+func GenerateOIDCLinkToken(userID uint, claims *OIDCClaims) (string, error) {
+	return signPurposeToken(userID, oidcLinkPurpose, time.Hour, jwt.MapClaims{
+		"iss_oidc": claims.Issuer,
+		"sub_oidc": claims.Subject,
+		"email":    claims.Email,
+	})
+}
+
+// ParseOIDCLinkToken validates a link token and returns the user and the identity to link
+func ParseOIDCLinkToken(tokenString string) (uint, *OIDCClaims, error) {
+	userID, claims, err := parsePurposeToken(tokenString, oidcLinkPurpose)
+	if err != nil {
+		return 0, nil, errors.New("invalid link token")
+	}
+
+	issuer, _ := claims["iss_oidc"].(string)
+	subject, _ := claims["sub_oidc"].(string)
+	email, _ := claims["email"].(string)
+	if issuer == "" || subject == "" || email == "" {
+		return 0, nil, errors.New("invalid link token")
+	}
+
+	return userID, &OIDCClaims{Issuer: issuer, Subject: subject, Email: email, EmailVerified: true}, nil
+}
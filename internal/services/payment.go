@@ -1,12 +1,19 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	xendit "github.com/xendit/xendit-go"
 	"github.com/xendit/xendit-go/invoice"
-	"os"
+	"net/http"
 )
 
+// PaymentGateway creates payment invoices, PaymentService is the Xendit implementation
+type PaymentGateway interface {
+	CreatePayment(userEmail string, amount float64, rentalID uint) (*Invoice, error)
+	Ping(ctx context.Context) error
+}
+
 type PaymentService struct{}
 
 // Invoice adalah struct untuk response payment
@@ -20,11 +27,25 @@ type Invoice struct {
 	InvoiceURL  string  `json:"invoice_url"`
 }
 
-func NewPaymentService() *PaymentService {
-	xendit.Opt.SecretKey = os.Getenv("XENDIT_SECRET_KEY")
+func NewPaymentService(secretKey string) *PaymentService {
+	xendit.Opt.SecretKey = secretKey
 	return &PaymentService{}
 }
 
+// Ping checks that the Xendit API answers, any HTTP response counts as reachable
+func (s *PaymentService) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, xendit.Opt.XenditURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
 func (s *PaymentService) CreatePayment(userEmail string, amount float64, rentalID uint) (*Invoice, error) {
 	// Buat parameter untuk invoice xendit
 	params := invoice.CreateParams{
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrNonPublicAddress is returned for URLs that resolve to loopback, private or link-local addresses
+var ErrNonPublicAddress = errors.New("address is not publicly routable")
+
+// sharedAddressSpace is the carrier-grade NAT range, not covered by net.IP.IsPrivate
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// PublicIP reports whether ip may be reached from user-supplied URLs
+func PublicIP(ip net.IP) bool {
+	return ip.IsGlobalUnicast() &&
+		!ip.IsPrivate() &&
+		!ip.IsLoopback() &&
+		!ip.IsLinkLocalUnicast() &&
+		!sharedAddressSpace.Contains(ip)
+}
+
+// ValidatePublicURL checks that a user-supplied URL is http(s) and that every
+// address its host resolves to is public. The dialer of NewPublicHTTPClient
+// checks again at connect time, since DNS can change after validation.
+func ValidatePublicURL(ctx context.Context, raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Hostname() == "" {
+		return errors.New("URL must be an http or https URL")
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, parsed.Hostname())
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", parsed.Hostname(), err)
+	}
+	for _, addr := range addrs {
+		if !PublicIP(addr.IP) {
+			return fmt.Errorf("%s: %w", parsed.Hostname(), ErrNonPublicAddress)
+		}
+	}
+	return nil
+}
+
+// NewPublicHTTPClient returns a client that refuses to connect to non-public
+// addresses, including after redirects and DNS rebinding. It ignores proxy
+// settings, a proxy would hide the real destination from the check.
+func NewPublicHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !PublicIP(ip) {
+				return fmt.Errorf("dial %s: %w", address, ErrNonPublicAddress)
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
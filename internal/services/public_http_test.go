@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPublicIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false}, // cloud metadata
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		if got := PublicIP(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("PublicIP(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestValidatePublicURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{"https://93.184.216.34/hook", false},
+		{"http://127.0.0.1:8080/hook", true},
+		{"http://[::1]/hook", true},
+		{"http://169.254.169.254/latest/meta-data", true},
+		{"ftp://93.184.216.34/hook", true},
+		{"not a url", true},
+	}
+
+	for _, tt := range tests {
+		err := ValidatePublicURL(context.Background(), tt.url)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidatePublicURL(%s) = %v, wantErr %v", tt.url, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPublicHTTPClientRefusesLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached a loopback server")
+	}))
+	defer server.Close()
+
+	_, err := NewPublicHTTPClient(time.Second).Get(server.URL)
+	if !errors.Is(err, ErrNonPublicAddress) {
+		t.Fatalf("err = %v, want ErrNonPublicAddress", err)
+	}
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"car-rental/internal/models"
+	"gorm.io/gorm"
+	"sync"
+	"time"
+)
+
+// RateLimitStore records login failures per key (IP or account)
+type RateLimitStore interface {
+	RecordFailure(key string, at time.Time) error
+	Failures(key string, since time.Time) (count int, last time.Time, err error)
+	Reset(key string) error
+}
+
+// MemoryRateLimitStore keeps failures in process memory
+type MemoryRateLimitStore struct {
+	mu       sync.Mutex
+	failures map[string][]time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{failures: map[string][]time.Time{}}
+}
+
+func (s *MemoryRateLimitStore) RecordFailure(key string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[key] = append(s.failures[key], at)
+	return nil
+}
+
+func (s *MemoryRateLimitStore) Failures(key string, since time.Time) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Drop failures outside the window
+	kept := s.failures[key][:0]
+	for _, at := range s.failures[key] {
+		if at.After(since) {
+			kept = append(kept, at)
+		}
+	}
+	if len(kept) == 0 {
+		delete(s.failures, key)
+		return 0, time.Time{}, nil
+	}
+	s.failures[key] = kept
+
+	return len(kept), kept[len(kept)-1], nil
+}
+
+func (s *MemoryRateLimitStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.failures, key)
+	return nil
+}
+
+// DatabaseRateLimitStore shares failures between instances through the database
+type DatabaseRateLimitStore struct {
+	db *gorm.DB
+}
+
+func NewDatabaseRateLimitStore(db *gorm.DB) *DatabaseRateLimitStore {
+	return &DatabaseRateLimitStore{db: db}
+}
+
+func (s *DatabaseRateLimitStore) RecordFailure(key string, at time.Time) error {
+	return s.db.Create(&models.RateLimitHit{Key: key, CreatedAt: at}).Error
+}
+
+func (s *DatabaseRateLimitStore) Failures(key string, since time.Time) (int, time.Time, error) {
+	var result struct {
+		Count int64
+		Last  *time.Time
+	}
+	if err := s.db.Model(&models.RateLimitHit{}).
+		Select("COUNT(*) AS count, MAX(created_at) AS last").
+		Where("key = ? AND created_at > ?", key, since).
+		Scan(&result).Error; err != nil {
+		return 0, time.Time{}, err
+	}
+	if result.Last == nil {
+		return 0, time.Time{}, nil
+	}
+	return int(result.Count), *result.Last, nil
+}
+
+func (s *DatabaseRateLimitStore) Reset(key string) error {
+	return s.db.Where("key = ?", key).Delete(&models.RateLimitHit{}).Error
+}
+
+// Purge removes hits older than the given time
+func (s *DatabaseRateLimitStore) Purge(before time.Time) error {
+	return s.db.Where("created_at < ?", before).Delete(&models.RateLimitHit{}).Error
+}
+
+// LoginLimiter applies per-IP limits and progressive per-account delays
+type LoginLimiter struct {
+	store        RateLimitStore
+	Window       time.Duration
+	MaxPerIP     int
+	FreeAttempts int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+}
+
+func NewLoginLimiter(store RateLimitStore) *LoginLimiter {
+	return &LoginLimiter{
+		store:        store,
+		Window:       15 * time.Minute,
+		MaxPerIP:     20,
+		FreeAttempts: 3,
+		BaseDelay:    time.Second,
+		MaxDelay:     time.Minute,
+	}
+}
+
+// CheckIP returns how long the IP must wait before trying again
+func (l *LoginLimiter) CheckIP(ip string) (time.Duration, error) {
+	count, last, err := l.store.Failures("ip:"+ip, time.Now().Add(-l.Window))
+	if err != nil {
+		return 0, err
+	}
+	if count < l.MaxPerIP {
+		return 0, nil
+	}
+	return time.Until(last.Add(l.Window)), nil
+}
+
+// CheckAccount returns the progressive delay still pending for the account
+func (l *LoginLimiter) CheckAccount(email string) (time.Duration, error) {
+	count, last, err := l.store.Failures("account:"+email, time.Now().Add(-l.Window))
+	if err != nil {
+		return 0, err
+	}
+	if count < l.FreeAttempts {
+		return 0, nil
+	}
+
+	// Delay doubles with every failure past the free attempts
+	delay := l.BaseDelay << uint(count-l.FreeAttempts)
+	if delay > l.MaxDelay || delay <= 0 {
+		delay = l.MaxDelay
+	}
+
+	wait := time.Until(last.Add(delay))
+	if wait < 0 {
+		return 0, nil
+	}
+	return wait, nil
+}
+
+// RecordFailure counts a failed login against both the IP and the account
+func (l *LoginLimiter) RecordFailure(ip, email string) error {
+	now := time.Now()
+	if err := l.store.RecordFailure("ip:"+ip, now); err != nil {
+		return err
+	}
+	return l.store.RecordFailure("account:"+email, now)
+}
+
+// ResetAccount clears the account failures after a successful login or unlock
+func (l *LoginLimiter) ResetAccount(email string) error {
+	return l.store.Reset("account:" + email)
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"bytes"
+	"car-rental/internal/config"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// SMSNotifier sends SMS or WhatsApp messages through an HTTP provider.
+// In development without a provider it runs as a stub that only logs them.
+type SMSNotifier struct {
+	channel  string
+	endpoint string
+	token    string
+	sender   string
+	stub     bool
+	client   *http.Client
+}
+
+var (
+	smsLinkPattern  = regexp.MustCompile(`https?://\S+`)
+	smsTokenPattern = regexp.MustCompile(`[A-Za-z0-9_\-.]{20,}`)
+)
+
+// redactMessage hides links and long tokens, the stub log must not hand out
+// working reset or verification links
+func redactMessage(message string) string {
+	message = smsLinkPattern.ReplaceAllString(message, "[link]")
+	return smsTokenPattern.ReplaceAllString(message, "[token]")
+}
+
+func NewSMSNotifier(channel string, cfg config.SMS) *SMSNotifier {
+	return &SMSNotifier{
+		channel:  channel,
+		endpoint: cfg.ProviderURL,
+		token:    cfg.Token.Value(),
+		sender:   cfg.Sender,
+		stub:     cfg.Stub,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (n *SMSNotifier) Channel() string {
+	return n.channel
+}
+
+func (n *SMSNotifier) Send(notification Notification) error {
+	if notification.Recipient == "" {
+		return fmt.Errorf("no phone number for %s message", n.channel)
+	}
+
+	if n.endpoint == "" && !n.stub {
+		return fmt.Errorf("%w: %s", ErrChannelUnavailable, n.channel)
+	}
+
+	message := notification.Subject + ": " + notification.Text
+
+	if n.endpoint == "" {
+		fmt.Printf("[%s stub] to %s: %s\n", n.channel, notification.Recipient, redactMessage(message))
+		return nil
+	}
+
+	payload, err := json.Marshal(map[string]string{
+		"channel": n.channel,
+		"from":    n.sender,
+		"to":      notification.Recipient,
+		"message": message,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, n.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.token)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s provider returned %d: %s", n.channel, resp.StatusCode, body)
+	}
+	return nil
+}
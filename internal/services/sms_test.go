@@ -0,0 +1,40 @@
+package services
+
+import (
+	"car-rental/internal/config"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRedactMessage(t *testing.T) {
+	message := "Reset password: open https://rental.example.com/reset-password?token=abc123 or use code eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOjF9.sig within 1 hour"
+
+	got := redactMessage(message)
+	for _, secret := range []string{"https://", "abc123", "eyJhbGciOiJIUzI1NiJ9"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("redacted message %q still contains %q", got, secret)
+		}
+	}
+	if !strings.HasPrefix(got, "Reset password: open [link]") || !strings.HasSuffix(got, "within 1 hour") {
+		t.Errorf("redacted message %q lost its text", got)
+	}
+}
+
+func TestSMSNotifierWithoutProvider(t *testing.T) {
+	notification := Notification{Recipient: "+628123456789", Subject: "Rental", Text: "Your car is ready"}
+
+	unavailable := NewSMSNotifier(ChannelSMS, config.SMS{})
+	if err := unavailable.Send(notification); !errors.Is(err, ErrChannelUnavailable) {
+		t.Errorf("Send without provider = %v, want ErrChannelUnavailable", err)
+	}
+
+	stub := NewSMSNotifier(ChannelSMS, config.SMS{Stub: true})
+	if err := stub.Send(notification); err != nil {
+		t.Errorf("stub Send = %v, want nil", err)
+	}
+
+	if ChannelAvailable(ChannelSMS, config.SMS{}) || !ChannelAvailable(ChannelSMS, config.SMS{Stub: true}) {
+		t.Error("SMS should be available with the stub and only then")
+	}
+}
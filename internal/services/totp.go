@@ -0,0 +1,114 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	totpPeriod          = 30
+	totpDigits          = 6
+	totpSkew            = 1 // accepted steps before and after the current one
+	twoFactorPurpose    = "2fa_challenge"
+	twoFactorChallenge  = 5 * time.Minute
+	recoveryCodeCount   = 10
+	recoveryCodeCharset = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+// GenerateTOTPSecret returns a random base32 secret
+func GenerateTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b), nil
+}
+
+// TOTPURI builds the otpauth URI rendered as a QR code by authenticator apps
+func TOTPURI(secret, email, issuer string) string {
+	label := url.PathEscape(issuer + ":" + email)
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprintf("%d", totpDigits))
+	params.Set("period", fmt.Sprintf("%d", totpPeriod))
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// totpCode computes the code for a time step (RFC 6238)
+func totpCode(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// ValidateTOTP checks a code against the secret and returns the matched time step
+func ValidateTOTP(secret, code string, at time.Time) (int64, bool) {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return 0, false
+	}
+
+	code = strings.TrimSpace(code)
+	current := at.Unix() / totpPeriod
+	for i := -totpSkew; i <= totpSkew; i++ {
+		step := current + int64(i)
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// GenerateRecoveryCodes returns single-use recovery codes formatted as xxxxx-xxxxx
+func GenerateRecoveryCodes() ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		for j := range b {
+			b[j] = recoveryCodeCharset[int(b[j])%len(recoveryCodeCharset)]
+		}
+		codes = append(codes, string(b[:5])+"-"+string(b[5:]))
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode lowercases a recovery code and strips separators
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
+
+// GenerateTwoFactorChallenge signs the token exchanged for a JWT after the TOTP step
+func GenerateTwoFactorChallenge(userID uint) (string, error) {
+	return signPurposeToken(userID, twoFactorPurpose, twoFactorChallenge, nil)
+}
+
+// ParseTwoFactorChallenge validates a challenge token and returns its user
+func ParseTwoFactorChallenge(tokenString string) (uint, error) {
+	userID, _, err := parsePurposeToken(tokenString, twoFactorPurpose)
+	if err != nil {
+		return 0, errors.New("invalid challenge token")
+	}
+	return userID, nil
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA1 key of the RFC 6238 appendix B test vectors
+var rfc6238Secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPMatchesRFC6238Vectors(t *testing.T) {
+	// The RFC lists 8 digit codes, our codes are their last 6 digits
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	for _, tt := range tests {
+		want := tt.code[len(tt.code)-totpDigits:]
+		if got := totpCode([]byte("12345678901234567890"), tt.unix/totpPeriod); got != want {
+			t.Errorf("totpCode at %d = %s, want %s", tt.unix, got, want)
+		}
+
+		step, ok := ValidateTOTP(rfc6238Secret, want, time.Unix(tt.unix, 0))
+		if !ok || step != tt.unix/totpPeriod {
+			t.Errorf("ValidateTOTP at %d = (%d, %v), want (%d, true)", tt.unix, step, ok, tt.unix/totpPeriod)
+		}
+	}
+}
+
+func TestValidateTOTPAcceptsOneStepOfSkew(t *testing.T) {
+	// 94287082 is the code of step 1 (unix 30-59)
+	code := "287082"
+	tests := []struct {
+		unix int64
+		ok   bool
+	}{
+		{0, true},    // step 0, one step behind the code
+		{45, true},   // step 1
+		{60, true},   // step 2, one step ahead of the code
+		{90, false},  // step 3
+		{-60, false}, // step -2
+	}
+
+	for _, tt := range tests {
+		step, ok := ValidateTOTP(rfc6238Secret, code, time.Unix(tt.unix, 0))
+		if ok != tt.ok {
+			t.Errorf("ValidateTOTP at %d ok = %v, want %v", tt.unix, ok, tt.ok)
+		}
+		if ok && step != 1 {
+			t.Errorf("ValidateTOTP at %d step = %d, want the step of the code (1)", tt.unix, step)
+		}
+	}
+}
+
+func TestValidateTOTPRejectsMalformedInput(t *testing.T) {
+	at := time.Unix(59, 0)
+	if _, ok := ValidateTOTP("not base32!", "287082", at); ok {
+		t.Error("accepted a code for an invalid secret")
+	}
+	if _, ok := ValidateTOTP(rfc6238Secret, "", at); ok {
+		t.Error("accepted an empty code")
+	}
+	if _, ok := ValidateTOTP(rfc6238Secret, " 287082 ", at); !ok {
+		t.Error("rejected a code with surrounding spaces")
+	}
+}
@@ -0,0 +1,31 @@
+package services
+
+import (
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"time"
+)
+
+const verificationPurpose = "verify_email"
+
+// GenerateVerificationToken signs an email verification link token
+func GenerateVerificationToken(userID uint, email string) (string, error) {
+	return signPurposeToken(userID, verificationPurpose, time.Hour*48, jwt.MapClaims{
+		"email": email,
+	})
+}
+
+// ParseVerificationToken validates a verification token and returns its user and email
+func ParseVerificationToken(tokenString string) (uint, string, error) {
+	userID, claims, err := parsePurposeToken(tokenString, verificationPurpose)
+	if err != nil {
+		return 0, "", errors.New("invalid verification token")
+	}
+
+	email, ok := claims["email"].(string)
+	if !ok {
+		return 0, "", errors.New("invalid verification token")
+	}
+
+	return userID, email, nil
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"car-rental/internal/config"
+	"testing"
+	"time"
+)
+
+func useTestSecret(t *testing.T) {
+	t.Helper()
+
+	previous := jwtSecret
+	t.Cleanup(func() { jwtSecret = previous })
+	ConfigureJWT(config.JWT{Secret: "test-secret", AccessTokenTTL: 15 * time.Minute, RefreshTokenTTL: 24 * time.Hour})
+}
+
+func TestVerificationToken(t *testing.T) {
+	useTestSecret(t)
+
+	token, err := GenerateVerificationToken(42, "rider@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userID, email, err := ParseVerificationToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID != 42 || email != "rider@example.com" {
+		t.Fatalf("parsed user %d and email %q, want 42 and rider@example.com", userID, email)
+	}
+
+	if _, _, err := ParseVerificationToken(token + "x"); err == nil {
+		t.Error("tampered token accepted")
+	}
+}
+
+func TestVerificationTokenRejectsOtherPurposes(t *testing.T) {
+	useTestSecret(t)
+
+	challenge, err := signPurposeToken(42, twoFactorPurpose, time.Minute, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ParseVerificationToken(challenge); err == nil {
+		t.Error("two-factor challenge accepted as a verification token")
+	}
+
+	expired, err := signPurposeToken(42, verificationPurpose, -time.Minute, map[string]interface{}{"email": "rider@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ParseVerificationToken(expired); err == nil {
+		t.Error("expired verification token accepted")
+	}
+}
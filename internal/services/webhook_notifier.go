@@ -0,0 +1,57 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// WebhookNotifier posts notifications as JSON to a user-configured URL,
+// only ever to public addresses
+type WebhookNotifier struct {
+	client *http.Client
+}
+
+func NewWebhookNotifier() *WebhookNotifier {
+	return &WebhookNotifier{client: NewPublicHTTPClient(10 * time.Second)}
+}
+
+func (n *WebhookNotifier) Channel() string {
+	return ChannelWebhook
+}
+
+func (n *WebhookNotifier) Send(notification Notification) error {
+	if notification.Recipient == "" {
+		return fmt.Errorf("no webhook URL configured")
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"type":    notification.Type,
+		"subject": notification.Subject,
+		"message": notification.Text,
+		"sent_at": time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, notification.Recipient, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "car-rental-notifier/1.0")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %d", resp.StatusCode)
+	}
+	return nil
+}
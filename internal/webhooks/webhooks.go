@@ -0,0 +1,263 @@
+// Package webhooks delivers domain events to integrator endpoints. Each
+// delivery is stored in webhook_deliveries, signed with the endpoint secret
+// and retried with the same backoff as the notification outbox.
+package webhooks
+
+import (
+	"bytes"
+	"car-rental/internal/metrics"
+	"car-rental/internal/models"
+	"car-rental/internal/outbox"
+	"car-rental/internal/services"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxAttempts = 8
+	lockDuration       = 2 * time.Minute
+	requestTimeout     = 10 * time.Second
+	maxResponseBody    = 1024
+)
+
+// Sign returns the signature header value for a payload sent at timestamp.
+// Receivers recompute HMAC-SHA256(secret, "<timestamp>.<body>") and compare.
+func Sign(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(payload)
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Enqueue stores a delivery of the payload to an endpoint
+func Enqueue(db *gorm.DB, endpointID uint, eventID, eventType string, payload []byte) error {
+	return db.Create(&models.WebhookDelivery{
+		EndpointID:    endpointID,
+		EventID:       eventID,
+		EventType:     eventType,
+		Payload:       string(payload),
+		Status:        "pending",
+		MaxAttempts:   defaultMaxAttempts,
+		NextAttemptAt: time.Now(),
+	}).Error
+}
+
+// Redeliver queues a fresh delivery with the same payload, keeping the original in the log
+func Redeliver(db *gorm.DB, id uint) (*models.WebhookDelivery, error) {
+	var original models.WebhookDelivery
+	if err := db.First(&original, id).Error; err != nil {
+		return nil, err
+	}
+
+	delivery := models.WebhookDelivery{
+		EndpointID:    original.EndpointID,
+		EventID:       original.EventID,
+		EventType:     original.EventType,
+		Payload:       original.Payload,
+		Status:        "pending",
+		MaxAttempts:   defaultMaxAttempts,
+		NextAttemptAt: time.Now(),
+		RedeliveryOf:  &original.ID,
+	}
+	if err := db.Create(&delivery).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// Dispatcher polls webhook_deliveries and sends them with a pool of workers
+type Dispatcher struct {
+	db       *gorm.DB
+	client   *http.Client
+	workers  int
+	interval time.Duration
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func NewDispatcher(db *gorm.DB, workers int) *Dispatcher {
+	if workers <= 0 {
+		workers = 1
+	}
+	// Endpoint URLs come from users, so the client refuses internal addresses
+	// and a redirect is recorded as the response instead of being followed
+	client := services.NewPublicHTTPClient(requestTimeout)
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return &Dispatcher{
+		db:       db,
+		client:   client,
+		workers:  workers,
+		interval: 5 * time.Second,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start launches the workers
+func (d *Dispatcher) Start() {
+	for i := 0; i < d.workers; i++ {
+		d.wg.Add(1)
+		go d.run()
+	}
+}
+
+// Stop waits for in-flight deliveries to finish
+func (d *Dispatcher) Stop() {
+	close(d.stop)
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) run() {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		// Drain everything due before sleeping again
+		for {
+			select {
+			case <-d.stop:
+				return
+			default:
+			}
+
+			delivery, err := d.claim()
+			if err != nil {
+				fmt.Printf("Error claiming webhook delivery: %v\n", err)
+				break
+			}
+			if delivery == nil {
+				break
+			}
+			d.deliver(delivery)
+		}
+
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim locks the next due delivery, skipping rows other workers hold
+func (d *Dispatcher) claim() (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	now := time.Now()
+
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status = ? AND next_attempt_at <= ?) OR (status = ? AND locked_until < ?)",
+				"pending", now, "processing", now).
+			Order("next_attempt_at ASC").
+			First(&delivery).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&delivery).Updates(map[string]interface{}{
+			"status":       "processing",
+			"locked_until": now.Add(lockDuration),
+		}).Error
+	})
+
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// deliver posts one delivery and records the response
+func (d *Dispatcher) deliver(delivery *models.WebhookDelivery) {
+	attempts := delivery.Attempts + 1
+	updates := map[string]interface{}{
+		"attempts":     attempts,
+		"locked_until": nil,
+	}
+
+	var endpoint models.WebhookEndpoint
+	code, body, sendErr := 0, "", error(nil)
+	if err := d.db.First(&endpoint, delivery.EndpointID).Error; err != nil {
+		sendErr = fmt.Errorf("endpoint not found")
+		attempts = delivery.MaxAttempts
+	} else if !endpoint.Active {
+		sendErr = fmt.Errorf("endpoint is disabled")
+		attempts = delivery.MaxAttempts
+	} else {
+		start := time.Now()
+		code, body, sendErr = d.post(endpoint, delivery)
+		metrics.WebhookDuration.Observe(time.Since(start).Seconds())
+	}
+
+	if code != 0 {
+		updates["response_code"] = code
+		updates["response_body"] = body
+	}
+
+	result := "failed"
+	switch {
+	case sendErr == nil:
+		updates["status"] = "delivered"
+		updates["delivered_at"] = time.Now()
+		updates["last_error"] = ""
+		result = "delivered"
+	case attempts >= delivery.MaxAttempts:
+		updates["status"] = "dead"
+		updates["last_error"] = sendErr.Error()
+		result = "dead"
+		fmt.Printf("Webhook delivery %d dead after %d attempts: %v\n", delivery.ID, attempts, sendErr)
+	default:
+		updates["status"] = "pending"
+		updates["last_error"] = sendErr.Error()
+		updates["next_attempt_at"] = time.Now().Add(outbox.Backoff(attempts))
+	}
+	metrics.WebhookDeliveries.WithLabelValues(delivery.EventType, result).Inc()
+
+	if err := d.db.Model(delivery).Updates(updates).Error; err != nil {
+		fmt.Printf("Error recording webhook delivery %d: %v\n", delivery.ID, err)
+	}
+}
+
+// post sends the signed request, any non-2xx response counts as a failure
+func (d *Dispatcher) post(endpoint models.WebhookEndpoint, delivery *models.WebhookDelivery) (int, string, error) {
+	payload := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequest(http.MethodPost, endpoint.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "car-rental-webhooks/1.0")
+	req.Header.Set("X-Webhook-Id", delivery.EventID)
+	req.Header.Set("X-Webhook-Event", delivery.EventType)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", Sign(endpoint.Secret, timestamp, payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, string(body), fmt.Errorf("endpoint returned %d", resp.StatusCode)
+	}
+	return resp.StatusCode, string(body), nil
+}
@@ -0,0 +1,79 @@
+package webhooks
+
+import (
+	"car-rental/internal/services"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSign(t *testing.T) {
+	payload := []byte(`{"type":"rental.created"}`)
+
+	// Computed independently of Sign, as a receiver would
+	want := "v1=1257343a3940c0e167926bd845531af163336a58b3719ee8bba7b81a302172e8"
+	if got := Sign("whsec_test", 1700000000, payload); got != want {
+		t.Fatalf("Sign = %s, want %s", got, want)
+	}
+}
+
+func TestSignCoversEveryInput(t *testing.T) {
+	base := Sign("secret", 1700000000, []byte(`{"id":1}`))
+
+	variants := map[string]string{
+		"secret":    Sign("other", 1700000000, []byte(`{"id":1}`)),
+		"timestamp": Sign("secret", 1700000001, []byte(`{"id":1}`)),
+		"payload":   Sign("secret", 1700000000, []byte(`{"id":2}`)),
+	}
+	for changed, signature := range variants {
+		if signature == base {
+			t.Errorf("changing the %s does not change the signature", changed)
+		}
+	}
+}
+
+func TestSignMatchesReceiverCheck(t *testing.T) {
+	secret := "whsec_receiver"
+	timestamp := int64(1700000000)
+	payload := []byte(`{"type":"payment.paid","data":{"amount":150000}}`)
+
+	header := Sign(secret, timestamp, payload)
+	signature, ok := strings.CutPrefix(header, "v1=")
+	if !ok {
+		t.Fatalf("signature %q has no v1= prefix", header)
+	}
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "." + string(payload)))
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		t.Fatal("receiver check rejects the signature")
+	}
+}
+
+func TestDispatcherClientRefusesInternalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewDispatcher(nil, 1).client
+	_, err := client.Post(server.URL, "application/json", strings.NewReader(`{}`))
+	if !errors.Is(err, services.ErrNonPublicAddress) {
+		t.Fatalf("delivery to %s: err = %v, want ErrNonPublicAddress", server.URL, err)
+	}
+
+	redirect, _ := http.NewRequest(http.MethodPost, "https://example.com/hook", nil)
+	if err := client.CheckRedirect(redirect, []*http.Request{redirect}); err != http.ErrUseLastResponse {
+		t.Fatalf("CheckRedirect = %v, want http.ErrUseLastResponse", err)
+	}
+}
@@ -1,61 +1,323 @@
 package main
 
 import (
+	"car-rental/internal/config"
+	"car-rental/internal/events"
 	"car-rental/internal/handlers"
+	"car-rental/internal/metrics"
 	customMiddleware "car-rental/internal/middleware"
+	"car-rental/internal/oidcmock"
+	"car-rental/internal/outbox"
+	"car-rental/internal/repository"
+	"car-rental/internal/services"
+	"car-rental/internal/webhooks"
 	"car-rental/pkg/database"
-	"github.com/joho/godotenv"
+	"context"
+	"errors"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 )
 
 func main() {
-	// Load .env
-	if err := godotenv.Load(); err != nil {
-		log.Fatal("Error loading .env file")
+	// Load configuration from defaults, .env, the environment and flags
+	cfg, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal("Invalid configuration:\n", err)
 	}
+	log.Printf("Starting in %s mode", cfg.App.Env)
 
-	// Initialize database
-	database.InitDB()
+	// Initialize database, timing every query and exporting pool stats
+	database.InitDB(cfg.Database)
+	if err := database.DB.Use(metrics.GormPlugin{}); err != nil {
+		log.Fatal("Failed to register query metrics:", err)
+	}
+	if sqlDB, err := database.DB.DB(); err == nil {
+		metrics.RegisterDBStats(sqlDB)
+	}
+
+	// Schema migrations, `migrate up|down [steps]|status|baseline` runs them and exits
+	if len(args) > 0 && args[0] == "migrate" {
+		runMigrate(args[1:])
+		return
+	}
+	if cfg.Database.AutoMigrate {
+		autoMigrate()
+	}
+
+	// Cancelled on SIGINT or SIGTERM, background jobs stop with it
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	var jobs sync.WaitGroup
+
+	// Token secret and lifetimes, and the base URL for emailed links
+	services.ConfigureJWT(cfg.JWT)
+	handlers.Configure(cfg)
+
+	// Load JWT signing keys
+	if err := services.InitKeys(cfg.JWT); err != nil {
+		log.Fatal("Failed to load JWT keys:", err)
+	}
+	if services.Keys != nil && cfg.JWT.KeyRotation > 0 {
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			services.Keys.StartRotation(ctx, cfg.JWT.KeyRotation)
+		}()
+	}
+
+	// Services and repositories, built once and shared by the handlers
+	emailService := services.NewEmailService(cfg.SMTP)
+	paymentService := services.NewPaymentService(cfg.Xendit.SecretKey.Value())
+	h := handlers.NewHandler(database.DB, repository.NewGorm(database.DB), emailService, paymentService)
+	h.Ready = newReadinessChecker(cfg.App, emailService, paymentService)
+
+	// Login rate limit store, shared through the database when running multiple instances
+	if cfg.App.RateLimitStore == "database" {
+		store := services.NewDatabaseRateLimitStore(database.DB)
+		h.Limiter = services.NewLoginLimiter(store)
+		runEvery(ctx, &jobs, time.Hour, func() {
+			store.Purge(time.Now().Add(-24 * time.Hour))
+		})
+	}
+
+	// React to domain events after their transaction commits
+	h.RegisterSubscribers(events.Default)
+
+	// Deliver queued notification emails
+	dispatcher := outbox.NewDispatcher(database.DB, h.Notifiers, cfg.Workers.Outbox)
+	dispatcher.Start()
+
+	// Deliver outgoing webhooks to integrators
+	webhookDispatcher := webhooks.NewDispatcher(database.DB, cfg.Workers.Webhook)
+	webhookDispatcher.Start()
+
+	// Release expired waitlist holds
+	runEvery(ctx, &jobs, time.Minute, h.ExpireWaitlistHolds)
+
+	// Send pickup, return-due and overdue rental reminders
+	runEvery(ctx, &jobs, 5*time.Minute, h.SendRentalReminders)
+
+	// Feed the open event streams from the shared audit log
+	runEvery(ctx, &jobs, handlers.EventStreamPoll, h.PollEventStreams)
+
+	// Purge expired entries from the token denylist
+	runEvery(ctx, &jobs, time.Hour, func() {
+		h.PurgeRevokedTokens()
+		h.PurgeOIDCStates()
+	})
 
 	// Create Echo instance
 	e := echo.New()
 
-	// Middleware
-	e.Use(middleware.Logger())
+	// Client IPs feed the login limits and session records, X-Forwarded-For is
+	// only believed when the request comes through one of our proxies
+	proxies, _ := cfg.App.TrustedProxyRanges() // validated by config.Load
+	if len(proxies) > 0 {
+		trust := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+		for _, proxy := range proxies {
+			trust = append(trust, echo.TrustIPRange(proxy))
+		}
+		e.IPExtractor = echo.ExtractIPFromXFFHeader(trust...)
+	} else {
+		e.IPExtractor = echo.ExtractIPDirect()
+	}
+
+	// Open event streams never finish on their own, end them when shutdown starts
+	e.Server.RegisterOnShutdown(handlers.CloseEventStreams)
+
+	// Middleware, probes are polled constantly and kept out of the request log
+	e.Use(middleware.LoggerWithConfig(middleware.LoggerConfig{
+		Skipper: func(c echo.Context) bool {
+			switch c.Path() {
+			case "/healthz", "/readyz", "/version", "/metrics":
+				return true
+			}
+			return false
+		},
+	}))
+	e.Use(metrics.Middleware())
 	e.Use(middleware.Recover())
 
+	// Probes, build info and metrics, public and outside the JWT group
+	e.GET("/healthz", h.Healthz)
+	e.GET("/readyz", h.Readyz)
+	e.GET("/version", h.Version)
+	e.GET("/metrics", echo.WrapHandler(metrics.Handler()), customMiddleware.MetricsToken(cfg.App.MetricsToken.Value()))
+
 	// Public routes
-	e.GET("/", handlers.GetCars)
-	e.POST("/api/v1/register", handlers.Register)
-	e.POST("/api/v1/login", handlers.Login)
+	e.GET("/", h.GetCars)
+	e.GET("/.well-known/jwks.json", h.GetJWKS)
+	e.POST("/api/v1/register", h.Register)
+	e.POST("/api/v1/login", h.Login)
+	e.POST("/api/v1/login/2fa", h.LoginTwoFactor)
+	e.POST("/api/v1/token/refresh", h.RefreshToken)
+	e.POST("/api/v1/password/forgot", h.ForgotPassword)
+	e.POST("/api/v1/password/reset", h.ResetPassword)
+	e.GET("/api/v1/verify-email", h.VerifyEmail)
+	e.GET("/api/v1/unlock-account", h.UnlockAccount)
+	e.GET("/api/v1/oidc/login", h.OIDCLogin)
+	e.GET("/api/v1/oidc/callback", h.OIDCCallback)
+	e.GET("/api/v1/oidc/link/confirm", h.ConfirmOIDCLink)
+	e.GET("/api/v1/events/stream", h.StreamEvents) // opened with a token from /events/token
+
+	// Mock OIDC provider for local development, served at the OIDC_ISSUER path
+	if cfg.OIDC.Mock {
+		provider, err := oidcmock.New(cfg.OIDC.Issuer, cfg.OIDC.ClientID, cfg.OIDC.ClientSecret.Value(), cfg.OIDC.MockEmail)
+		if err != nil {
+			log.Fatal("Failed to start mock OIDC provider:", err)
+		}
+		issuer, _ := url.Parse(cfg.OIDC.Issuer) // validated by config.Load
+		e.Any(strings.TrimSuffix(issuer.Path, "/")+"/*", echo.WrapHandler(provider))
+		log.Println("Mock OIDC provider enabled at", cfg.OIDC.Issuer)
+	}
 
 	// Protected routes
 	api := e.Group("/api/v1")
 	api.Use(customMiddleware.JWT)
 
+	// Auth routes
+	api.POST("/logout", h.Logout)
+	api.POST("/password/change", h.ChangePassword)
+	api.GET("/sessions", h.ListSessions)
+	api.DELETE("/sessions", h.RevokeOtherSessions)
+	api.DELETE("/sessions/:id", h.RevokeSession)
+	api.POST("/verify-email/resend", h.ResendVerification)
+	api.POST("/2fa/enroll", h.EnrollTwoFactor)
+	api.POST("/2fa/confirm", h.ConfirmTwoFactor)
+	api.POST("/2fa/disable", h.DisableTwoFactor)
+	api.GET("/partner-access", h.GetPartnerAccess)
+	api.POST("/partner-access/:id/approve", h.ApprovePartnerAccess)
+	api.DELETE("/partner-access/:id", h.RevokePartnerAccess)
+
 	// User routes
-	api.GET("/profile", handlers.GetProfile)
-	api.POST("/topup", handlers.TopUp)
+	api.GET("/profile", h.GetProfile)
+	api.PUT("/profile", h.UpdateProfile)
+	api.POST("/profile/documents", h.UploadKYCDocument)
+	api.POST("/profile/kyc", h.SubmitKYC)
+	api.POST("/topup", h.TopUp, customMiddleware.VerifiedEmail)
+
+	// Notification routes
+	api.POST("/events/token", h.CreateEventStreamToken)
+	api.GET("/notifications", h.GetNotifications)
+	api.POST("/notifications/read-all", h.MarkAllNotificationsRead)
+	api.POST("/notifications/:id/read", h.MarkNotificationRead)
+	api.GET("/notifications/preferences", h.GetNotificationPreferences)
+	api.PUT("/notifications/preferences", h.UpdateNotificationPreferences)
 
 	// Car routes
-	api.GET("/cars", handlers.GetCars)
-	api.GET("/cars/:id", handlers.GetCarDetail)
+	api.GET("/cars", h.GetCars)
+	api.GET("/cars/:id", h.GetCarDetail)
+	api.POST("/cars/:id/waitlist", h.JoinWaitlist)
+	api.DELETE("/cars/:id/waitlist", h.LeaveWaitlist)
 
 	// Rental routes
-	api.POST("/rentals", handlers.CreateRental)
-	api.GET("/rentals", handlers.GetUserRentals)
-	api.POST("/rentals/:id/return", handlers.ReturnCar)
+	api.POST("/rentals", h.CreateRental, customMiddleware.VerifiedEmail)
+	api.GET("/rentals", h.GetUserRentals)
+	api.POST("/rentals/:id/return", h.ReturnCar)
 
 	// Payment routes
-	api.GET("/payments", handlers.GetPaymentHistory)
-	api.GET("/payments/:id", handlers.GetPaymentDetail)
-	api.POST("/payments/webhook", handlers.WebhookHandler)
+	api.GET("/payments", h.GetPaymentHistory)
+	api.GET("/payments/:id", h.GetPaymentDetail)
+	api.POST("/payments/webhook", h.WebhookHandler)
+
+	// Admin routes
+	admin := api.Group("/admin", customMiddleware.Admin)
+	admin.GET("/partners", h.GetPartners)
+	admin.POST("/partners", h.CreatePartner)
+	admin.GET("/partners/:id/keys", h.GetAPIKeys)
+	admin.POST("/partners/:id/keys", h.CreateAPIKey)
+	admin.DELETE("/api-keys/:id", h.RevokeAPIKey)
+	admin.GET("/kyc", h.GetKYCQueue)
+	admin.GET("/kyc/documents/:id", h.GetKYCDocument)
+	admin.POST("/kyc/:id/approve", h.ApproveKYC)
+	admin.POST("/kyc/:id/reject", h.RejectKYC)
+	admin.GET("/outbox", h.GetOutboxMessages)
+	admin.POST("/outbox/:id/requeue", h.RequeueOutboxMessage)
+	admin.GET("/webhooks", h.GetWebhookEndpoints)
+	admin.POST("/webhooks", h.CreateWebhookEndpoint)
+	admin.PUT("/webhooks/:id", h.UpdateWebhookEndpoint)
+	admin.DELETE("/webhooks/:id", h.DeleteWebhookEndpoint)
+	admin.GET("/webhooks/:id/deliveries", h.GetWebhookDeliveries)
+	admin.POST("/webhook-deliveries/:id/redeliver", h.RedeliverWebhook)
+	admin.GET("/audit-logs", h.GetAuditLogs)
+	admin.GET("/analytics/events", h.GetEventStats)
+	admin.GET("/email-templates", h.GetEmailTemplates)
+	admin.GET("/email-templates/:name/preview", h.PreviewEmailTemplate)
+
+	// Partner routes (API key)
+	partner := e.Group("/api/partner/v1")
+	partner.GET("/cars", h.GetCars, customMiddleware.APIKey("cars:read"))
+	partner.GET("/cars/:id", h.GetCarDetail, customMiddleware.APIKey("cars:read"))
+	partner.GET("/rentals", h.PartnerGetRentals, customMiddleware.APIKey("rentals:read"))
+	partner.POST("/rentals", h.PartnerCreateRental, customMiddleware.APIKey("rentals:write"))
+	partner.GET("/customers", h.PartnerGetCustomers, customMiddleware.APIKey("rentals:read"))
+	partner.POST("/customers", h.PartnerRequestCustomer, customMiddleware.APIKey("rentals:write"))
 
 	// Webhook route (public)
-	e.POST("/payments/webhook", handlers.WebhookHandler)
+	e.POST("/payments/webhook", h.WebhookHandler)
 
 	// Start server
-	e.Logger.Fatal(e.Start(":8080"))
+	serverErr := make(chan error, 1)
+	go func() {
+		if err := e.Start(cfg.App.ListenAddr); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	failed := false
+	select {
+	case <-ctx.Done():
+		log.Println("Shutting down, draining in-flight requests")
+	case err := <-serverErr:
+		log.Println("Server stopped:", err)
+		failed = true
+	}
+	stop()
+
+	// Stop accepting requests and let in-flight ones finish their transactions
+	drainCtx, cancel := context.WithTimeout(context.Background(), cfg.App.ShutdownTimeout)
+	defer cancel()
+	if err := e.Shutdown(drainCtx); err != nil {
+		log.Println("Error draining HTTP server:", err)
+	}
+
+	// Background jobs, then the workers delivering what requests queued
+	jobs.Wait()
+	dispatcher.Stop()
+	webhookDispatcher.Stop()
+
+	if err := database.Close(); err != nil {
+		log.Println("Error closing database:", err)
+	}
+	log.Println("Shutdown complete")
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// runEvery runs job on every tick of interval until ctx is cancelled
+func runEvery(ctx context.Context, jobs *sync.WaitGroup, interval time.Duration, job func()) {
+	jobs.Add(1)
+	go func() {
+		defer jobs.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				job()
+			}
+		}
+	}()
 }
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunEveryStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var jobs sync.WaitGroup
+	var runs int32
+	ran := make(chan struct{}, 1)
+
+	runEvery(ctx, &jobs, 5*time.Millisecond, func() {
+		atomic.AddInt32(&runs, 1)
+		select {
+		case ran <- struct{}{}:
+		default:
+		}
+	})
+
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("job never ran")
+	}
+
+	cancel()
+	stopped := make(chan struct{})
+	go func() {
+		jobs.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("runEvery did not stop after the context was cancelled")
+	}
+
+	after := atomic.LoadInt32(&runs)
+	time.Sleep(20 * time.Millisecond)
+	if got := atomic.LoadInt32(&runs); got != after {
+		t.Fatalf("job ran %d more times after stopping", got-after)
+	}
+}
+
+func TestRunEveryWaitsForRunningJob(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var jobs sync.WaitGroup
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var finished int32
+
+	var once sync.Once
+	runEvery(ctx, &jobs, 5*time.Millisecond, func() {
+		once.Do(func() {
+			close(started)
+			<-release
+			atomic.StoreInt32(&finished, 1)
+		})
+	})
+
+	<-started
+	cancel()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+	}()
+
+	// Shutdown waits for the job in flight instead of cutting it off
+	jobs.Wait()
+	if atomic.LoadInt32(&finished) != 1 {
+		t.Fatal("jobs.Wait returned before the running job finished")
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"car-rental/internal/migrations"
+	"car-rental/pkg/database"
+	"fmt"
+	"log"
+	"strconv"
+)
+
+// runMigrate handles `migrate up`, `migrate down [steps]`, `migrate status` and `migrate baseline`
+func runMigrate(args []string) {
+	sqlDB, err := database.DB.DB()
+	if err != nil {
+		log.Fatal("Failed to get database handle:", err)
+	}
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		applied, err := migrations.Up(sqlDB)
+		for _, m := range applied {
+			fmt.Printf("Applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal("Migration failed:", err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("Schema is up to date")
+		}
+	case "baseline":
+		adopted, err := migrations.Baseline(sqlDB)
+		if err != nil {
+			log.Fatal("Baseline failed:", err)
+		}
+		for _, m := range adopted {
+			fmt.Printf("Baselined %04d_%s\n", m.Version, m.Name)
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				log.Fatal("Steps must be a positive number")
+			}
+		}
+		reverted, err := migrations.Down(sqlDB, steps)
+		for _, m := range reverted {
+			fmt.Printf("Reverted %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal("Rollback failed:", err)
+		}
+	case "status":
+		statuses, err := migrations.Statuses(sqlDB)
+		if err != nil {
+			log.Fatal("Failed to read migration status:", err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.AppliedAt != nil {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-32s %s\n", s.Version, s.Name, state)
+		}
+	default:
+		log.Fatalf("Unknown migrate command %q, use up, down [steps], status or baseline", command)
+	}
+}
+
+// autoMigrate applies pending migrations on start when DB_AUTO_MIGRATE=true
+func autoMigrate() {
+	sqlDB, err := database.DB.DB()
+	if err != nil {
+		log.Fatal("Failed to get database handle:", err)
+	}
+
+	applied, err := migrations.Up(sqlDB)
+	if err != nil {
+		log.Fatal("Migration failed:", err)
+	}
+	for _, m := range applied {
+		log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+	}
+}
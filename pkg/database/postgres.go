@@ -1,37 +1,49 @@
 package database
 
 import (
-	"fmt"
+	"car-rental/internal/config"
 	"gorm.io/gorm"
 	"log"
-	"os"
 
 	"gorm.io/driver/postgres"
 )
 
 var DB *gorm.DB
 
-func InitDB() {
-	// Build DSN string from environment variables
-	dsn := fmt.Sprintf(
-		"host=%s user=%s password=%s dbname=%s port=%s sslmode=require",
-		os.Getenv("DB_HOST"),
-		os.Getenv("DB_USER"),
-		os.Getenv("DB_PASSWORD"),
-		os.Getenv("DB_NAME"),
-		os.Getenv("DB_PORT"),
-	)
-
-	// Log the DSN for debugging (you can remove this in production)
-	log.Println("Connecting to database with DSN:", dsn)
+func InitDB(cfg config.Database) {
+	// Log the DSN for debugging, the password is redacted
+	log.Println("Connecting to database with DSN:", cfg.RedactedDSN())
 
 	// Open database connection
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(cfg.DSN()), &gorm.Config{})
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
 
+	// Size the connection pool, a zero limit means unlimited
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatal("Failed to get database handle:", err)
+	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
 	// Assign the connection to the global variable
 	DB = db
-	log.Println("Database connected successfully")
+	log.Printf("Database connected successfully, pool max open %d, max idle %d", cfg.MaxOpenConns, cfg.MaxIdleConns)
+}
+
+// Close closes the connection pool behind DB
+func Close() error {
+	if DB == nil {
+		return nil
+	}
+
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
 }
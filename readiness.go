@@ -0,0 +1,60 @@
+package main
+
+import (
+	"car-rental/internal/config"
+	"car-rental/internal/health"
+	"car-rental/internal/migrations"
+	"car-rental/internal/services"
+	"car-rental/pkg/database"
+	"context"
+	"fmt"
+)
+
+// newReadinessChecker builds the /readyz checks. The database and schema
+// gate readiness, SMTP and the payment gateway are reported only, since
+// the outbox retries email and an outage there should not drain every instance.
+func newReadinessChecker(cfg config.App, email *services.EmailService, payment services.PaymentGateway) *health.Checker {
+	return health.NewChecker(cfg.ReadyCacheTTL,
+		health.Check{
+			Name:     "database",
+			Critical: true,
+			Timeout:  cfg.ReadyTimeout,
+			Run: func(ctx context.Context) error {
+				sqlDB, err := database.DB.DB()
+				if err != nil {
+					return err
+				}
+				return sqlDB.PingContext(ctx)
+			},
+		},
+		health.Check{
+			Name:     "migrations",
+			Critical: true,
+			Timeout:  cfg.ReadyTimeout,
+			Run: func(ctx context.Context) error {
+				sqlDB, err := database.DB.DB()
+				if err != nil {
+					return err
+				}
+				pending, err := migrations.Pending(ctx, sqlDB)
+				if err != nil {
+					return err
+				}
+				if pending > 0 {
+					return fmt.Errorf("%d migrations pending", pending)
+				}
+				return nil
+			},
+		},
+		health.Check{
+			Name:    "smtp",
+			Timeout: cfg.ReadyTimeout,
+			Run:     email.Ping,
+		},
+		health.Check{
+			Name:    "payment_gateway",
+			Timeout: cfg.ReadyTimeout,
+			Run:     payment.Ping,
+		},
+	)
+}